@@ -1,11 +1,17 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"fmt"
 	"net"
 	"os"
 	"os/user"
 	"path"
+	"strings"
 
+	"github.com/alexbakker/gonano/nano/crypto/ed25519"
 	"github.com/alexbakker/gonano/nano/node"
 	"github.com/alexbakker/gonano/nano/store"
 	"github.com/alexbakker/gonano/nano/store/genesis"
@@ -25,14 +31,50 @@ func prepareDir() string {
 		panic(err)
 	}
 
-	dir := path.Join(user.HomeDir, ".config/gonano/db")
-	if err = os.MkdirAll(dir, 0700); err != nil {
+	dir := path.Join(user.HomeDir, ".config/gonano")
+	if err = os.MkdirAll(path.Join(dir, "db"), 0700); err != nil {
 		panic(err)
 	}
 
 	return dir
 }
 
+// loadRepresentativeKeys reads the private keys of the representatives to vote
+// for from the given file. Every line of the file contains one hex encoded
+// private key. If the file doesn't exist, no keys are returned.
+func loadRepresentativeKeys(filename string) ([]ed25519.PrivateKey, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	var keys []ed25519.PrivateKey
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		seed, err := hex.DecodeString(line)
+		if err != nil || len(seed) != 32 {
+			return nil, fmt.Errorf("bad representative key in %s", filename)
+		}
+
+		_, key, err := ed25519.GenerateKey(bytes.NewReader(seed))
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, scanner.Err()
+}
+
 func main() {
 	ledgerOpts := store.LedgerOptions{
 		GenesisBlock:   genesis.LiveBlock,
@@ -48,8 +90,15 @@ func main() {
 	// create gonano config directory
 	dir := prepareDir()
 
+	// load the keys of the representatives to vote for
+	keys, err := loadRepresentativeKeys(path.Join(dir, "representatives"))
+	if err != nil {
+		panic(err)
+	}
+	nodeOpts.RepresentativeKeys = keys
+
 	// open the database
-	db, err := store.NewBadgerStore(dir)
+	db, err := store.NewBadgerStore(path.Join(dir, "db"))
 	if err != nil {
 		panic(err)
 	}
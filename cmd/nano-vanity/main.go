@@ -1,8 +1,8 @@
 package main
 
 import (
+	"flag"
 	"fmt"
-	"os"
 	"runtime"
 	"strings"
 
@@ -32,19 +32,23 @@ func findAddress(prefix string, c chan *result) {
 		}
 
 		addr := wallet.NewAccount(key).Address()
-		if strings.HasPrefix(addr.String()[5:], prefix) {
+		// skip the first character, it can only be a 1 or a 3
+		if strings.HasPrefix(addr.StringWithPrefix("")[1:], prefix) {
 			c <- &result{seed, addr, i}
 		}
 	}
 }
 
 func main() {
-	if len(os.Args) < 2 {
+	addressPrefix := flag.String("address-prefix", wallet.AddressPrefix, "the prefix to print the address with (xrb_ or nano_)")
+	flag.Parse()
+
+	if flag.NArg() < 1 {
 		fmt.Printf("error: no prefix specified\n")
 		return
 	}
 
-	prefix := os.Args[1]
+	prefix := flag.Arg(0)
 	for _, c := range prefix {
 		if !strings.ContainsRune(wallet.AddressEncodingAlphabet, c) {
 			fmt.Printf("error: char '%c' is not in nano's encoding alphabet\n", c)
@@ -62,7 +66,7 @@ func main() {
 	if res := <-c; res != nil {
 		fmt.Printf("found a match! (after %d iterations)\n", res.Iterations)
 		fmt.Printf("seed: %s\n", res.Seed)
-		fmt.Printf("address: %s\n", res.Address)
+		fmt.Printf("address: %s\n", res.Address.StringWithPrefix(*addressPrefix))
 	} else {
 		fmt.Printf("no match found!\n")
 	}
@@ -0,0 +1,476 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/alexbakker/gonano/nano/block"
+	"github.com/alexbakker/gonano/nano/node/proto"
+	"github.com/alexbakker/gonano/nano/wallet"
+)
+
+const (
+	defaultPeers = "rai.raiblocks.net:7075"
+)
+
+var (
+	ErrNotOpened = errors.New("account has not been opened yet")
+)
+
+// blockFlags contains the flags that are shared by the commands that create
+// blocks.
+type blockFlags struct {
+	db         *string
+	publish    *bool
+	peers      *string
+	threads    *int
+	precompute *bool
+}
+
+type blockResult struct {
+	Type      string      `json:"type"`
+	Hash      block.Hash  `json:"hash"`
+	Block     block.Block `json:"block"`
+	Published int         `json:"published,omitempty"`
+}
+
+type blocksResult struct {
+	Blocks []*blockResult `json:"blocks"`
+}
+
+func (r *blocksResult) text() string {
+	var lines []string
+	for _, blk := range r.Blocks {
+		line := fmt.Sprintf("%s block %s", blk.Type, blk.Hash)
+		if blk.Published > 0 {
+			line += fmt.Sprintf(" (published to %d peers)", blk.Published)
+		}
+		lines = append(lines, line)
+	}
+	if len(lines) == 0 {
+		return "no blocks"
+	}
+	return strings.Join(lines, "\n")
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface. The type field is
+// used to decide which kind of block to decode.
+func (r *blockResult) UnmarshalJSON(data []byte) error {
+	var res struct {
+		Type  string          `json:"type"`
+		Block json.RawMessage `json:"block"`
+	}
+	if err := json.Unmarshal(data, &res); err != nil {
+		return err
+	}
+
+	blk, err := block.NewFromName(res.Type)
+	if err != nil {
+		return err
+	}
+	if err = json.Unmarshal(res.Block, blk); err != nil {
+		return err
+	}
+
+	r.Type = res.Type
+	r.Hash = blk.Hash()
+	r.Block = blk
+	return nil
+}
+
+func newBlockFlags(flags *flag.FlagSet) *blockFlags {
+	return &blockFlags{
+		db:         flags.String("db", defaultDBDir(), "the ledger database"),
+		publish:    flags.Bool("publish", false, "publish the new blocks to the network"),
+		peers:      newPeersFlag(flags),
+		threads:    flags.Int("threads", 0, "the amount of threads to use for generating work (defaults to the amount of CPUs)"),
+		precompute: flags.Bool("precompute", false, "precompute the work for the next block of the account and save it in the wallet"),
+	}
+}
+
+func newPeersFlag(flags *flag.FlagSet) *string {
+	return flags.String("peers", defaultPeers, "comma separated list of peers to publish to")
+}
+
+// signalContext returns a context that is canceled on an interrupt.
+func signalContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt)
+	go func() {
+		select {
+		case <-c:
+			cancel()
+		case <-ctx.Done():
+		}
+		signal.Stop(c)
+	}()
+
+	return ctx, cancel
+}
+
+// blockCreator creates blocks for an account of a wallet.
+type blockCreator struct {
+	opts    *options
+	flags   *blockFlags
+	wallet  *wallet.Wallet
+	account *wallet.Account
+	gen     *block.WorkGenerator
+	res     *blocksResult
+}
+
+func newBlockCreator(opts *options, flags *blockFlags, w *wallet.Wallet, account *wallet.Account) *blockCreator {
+	return &blockCreator{
+		opts:    opts,
+		flags:   flags,
+		wallet:  w,
+		account: account,
+		gen:     block.NewWorkGenerator(*flags.threads),
+		res:     &blocksResult{Blocks: []*blockResult{}},
+	}
+}
+
+// builder returns a block builder for the account that generates work with the
+// generator of the creator.
+func (c *blockCreator) builder() *block.Builder {
+	return block.NewBuilder(c.account, c.gen)
+}
+
+func (c *blockCreator) add(blk block.Block) {
+	c.res.Blocks = append(c.res.Blocks, &blockResult{
+		Type:  block.Name(blk.ID()),
+		Hash:  blk.Hash(),
+		Block: blk,
+	})
+}
+
+// finish publishes the created blocks if that was requested and returns the
+// result of the command.
+func (c *blockCreator) finish() (*blocksResult, error) {
+	if *c.flags.publish {
+		if err := publish(c.res.Blocks, *c.flags.peers); err != nil {
+			return nil, err
+		}
+	}
+
+	return c.res, nil
+}
+
+// precompute generates the work for the block that follows the new head of the
+// account and saves it in the wallet if that was requested, so that the next
+// block can be created right away. It's meant to be called after the result of
+// the command is printed. An interrupt skips the precomputation.
+func (c *blockCreator) precompute(ctx context.Context) error {
+	if !*c.flags.precompute || len(c.res.Blocks) == 0 {
+		return nil
+	}
+
+	fmt.Fprintln(os.Stderr, "precomputing work for the next block, interrupt to skip")
+
+	head := c.res.Blocks[len(c.res.Blocks)-1].Hash
+	work, err := c.gen.Generate(ctx, head)
+	if err != nil {
+		if err == ctx.Err() {
+			return nil
+		}
+		return err
+	}
+
+	c.account.SetWork(head, uint64(work))
+	return c.wallet.Save(c.opts.wallet)
+}
+
+// publish sends the given blocks to the given comma separated list of peers.
+func publish(blocks []*blockResult, peers string) error {
+	var addrs []*net.UDPAddr
+	for _, peer := range strings.Split(peers, ",") {
+		addr, err := net.ResolveUDPAddr("udp", strings.TrimSpace(peer))
+		if err != nil {
+			return err
+		}
+		addrs = append(addrs, addr)
+	}
+
+	conn, err := net.ListenUDP("udp", nil)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	for _, blk := range blocks {
+		bytes, err := proto.MarshalPacket(&proto.PublishPacket{Type: blk.Block.ID(), Block: blk.Block})
+		if err != nil {
+			return err
+		}
+
+		for _, addr := range addrs {
+			if _, err := conn.WriteToUDP(bytes, addr); err != nil {
+				return err
+			}
+		}
+		blk.Published = len(addrs)
+	}
+
+	return nil
+}
+
+// openAccountDB loads the wallet, finds the account with the given address in
+// it and opens the ledger database.
+func openAccountDB(opts *options, address string, dbDir string) (*wallet.Wallet, *wallet.Account, *ledgerDB, error) {
+	w, err := opts.loadWallet()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	account, err := findAccount(w, address)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	db, err := openLedger(dbDir)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return w, account, db, nil
+}
+
+func runSend(args []string) error {
+	var opts options
+	flags := newFlagSet("send", &opts)
+	blockFlags := newBlockFlags(flags)
+	from := flags.String("from", "", "the account to send from")
+	to := flags.String("to", "", "the account to send to")
+	amountString := flags.String("amount", "", "the amount to send")
+	unit := flags.String("unit", "Mxrb", "the unit of the amount")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	destination, err := wallet.ParseAddress(*to)
+	if err != nil {
+		return err
+	}
+	amount, err := wallet.ParseBalance(*amountString, *unit)
+	if err != nil {
+		return err
+	}
+	if amount.Equal(wallet.ZeroBalance) {
+		return errors.New("amount should be bigger than zero")
+	}
+
+	w, account, db, err := openAccountDB(&opts, *from, *blockFlags.db)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	info, err := accountInfo(db, account.Address())
+	if err != nil {
+		return err
+	}
+	if info == nil {
+		return ErrNotOpened
+	}
+	if info.Balance.Compare(amount) == wallet.BalanceCompSmaller {
+		return fmt.Errorf("insufficient balance: %s Mxrb", info.Balance)
+	}
+
+	ctx, cancel := signalContext()
+	defer cancel()
+
+	creator := newBlockCreator(&opts, blockFlags, w, account)
+	blk, err := creator.builder().Send(ctx, info.HeadBlock, destination, info.Balance.Sub(amount))
+	if err != nil {
+		return err
+	}
+	creator.add(blk)
+
+	res, err := creator.finish()
+	if err != nil {
+		return err
+	}
+
+	opts.print(res)
+	return creator.precompute(ctx)
+}
+
+func runReceive(args []string) error {
+	var opts options
+	flags := newFlagSet("receive", &opts)
+	blockFlags := newBlockFlags(flags)
+	address := flags.String("account", "", "the account to receive with")
+	hashString := flags.String("hash", "", "only receive the send block with this hash")
+	repString := flags.String("rep", "", "the representative if the account needs to be opened (defaults to the account itself)")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	w, account, db, err := openAccountDB(&opts, *address, *blockFlags.db)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	rep := account.Address()
+	if *repString != "" {
+		if rep, err = wallet.ParseAddress(*repString); err != nil {
+			return err
+		}
+	}
+
+	info, err := accountInfo(db, account.Address())
+	if err != nil {
+		return err
+	}
+	pending, err := pendingBlocks(db, account.Address(), opts.prefix)
+	if err != nil {
+		return err
+	}
+
+	if *hashString != "" {
+		var hash block.Hash
+		if err = hash.UnmarshalText([]byte(*hashString)); err != nil {
+			return err
+		}
+
+		var selected []*pendingBlock
+		for _, blk := range pending {
+			if blk.Hash.Equal(hash) {
+				selected = append(selected, blk)
+			}
+		}
+		if len(selected) == 0 {
+			return fmt.Errorf("block %s is not pending for this account", hash)
+		}
+		pending = selected
+	}
+
+	var head block.Hash
+	if info != nil {
+		head = info.HeadBlock
+	}
+
+	ctx, cancel := signalContext()
+	defer cancel()
+
+	creator := newBlockCreator(&opts, blockFlags, w, account)
+	builder := creator.builder()
+	for _, source := range pending {
+		var blk block.Block
+		if head.IsZero() {
+			blk, err = builder.Open(ctx, source.Hash, rep)
+		} else {
+			blk, err = builder.Receive(ctx, head, source.Hash)
+		}
+		if err != nil {
+			return err
+		}
+		creator.add(blk)
+		head = blk.Hash()
+	}
+
+	res, err := creator.finish()
+	if err != nil {
+		return err
+	}
+
+	opts.print(res)
+	return creator.precompute(ctx)
+}
+
+func runChangeRep(args []string) error {
+	var opts options
+	flags := newFlagSet("change-rep", &opts)
+	blockFlags := newBlockFlags(flags)
+	address := flags.String("account", "", "the account to change the representative of")
+	repString := flags.String("rep", "", "the new representative")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	rep, err := wallet.ParseAddress(*repString)
+	if err != nil {
+		return err
+	}
+
+	w, account, db, err := openAccountDB(&opts, *address, *blockFlags.db)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	info, err := accountInfo(db, account.Address())
+	if err != nil {
+		return err
+	}
+	if info == nil {
+		return ErrNotOpened
+	}
+
+	ctx, cancel := signalContext()
+	defer cancel()
+
+	creator := newBlockCreator(&opts, blockFlags, w, account)
+	blk, err := creator.builder().Change(ctx, info.HeadBlock, rep)
+	if err != nil {
+		return err
+	}
+	creator.add(blk)
+
+	res, err := creator.finish()
+	if err != nil {
+		return err
+	}
+
+	opts.print(res)
+	return creator.precompute(ctx)
+}
+
+func runPublish(args []string) error {
+	var opts options
+	flags := newFlagSet("publish", &opts)
+	peers := newPeersFlag(flags)
+	filename := flags.String("file", "-", "the file with the blocks to publish, as written by --json (- for stdin)")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	var data []byte
+	var err error
+	if *filename == "-" {
+		data, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		data, err = ioutil.ReadFile(*filename)
+	}
+	if err != nil {
+		return err
+	}
+
+	var res blocksResult
+	if err = json.Unmarshal(data, &res); err != nil {
+		return err
+	}
+
+	for _, blk := range res.Blocks {
+		if !blk.Block.Valid() {
+			return fmt.Errorf("block %s has invalid work", blk.Hash)
+		}
+	}
+
+	if err = publish(res.Blocks, *peers); err != nil {
+		return err
+	}
+
+	opts.print(&res)
+	return nil
+}
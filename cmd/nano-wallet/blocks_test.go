@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/alexbakker/gonano/nano/store/genesis"
+	"github.com/alexbakker/gonano/nano/wallet"
+)
+
+// testWorkThreshold is low enough to generate work in tests.
+const testWorkThreshold = 0xff00000000000000
+
+func TestBlocksResult(t *testing.T) {
+	res := &blocksResult{Blocks: []*blockResult{}}
+	if text := res.text(); text != "no blocks" {
+		t.Fatalf("unexpected text: %s", text)
+	}
+
+	res.Blocks = append(res.Blocks, &blockResult{
+		Type:      "open",
+		Hash:      genesis.LiveBlock.Hash(),
+		Block:     genesis.LiveBlock,
+		Published: 2,
+	})
+	expected := "open block " + genesis.LiveBlock.Hash().String() + " (published to 2 peers)"
+	if text := res.text(); text != expected {
+		t.Fatalf("unexpected text: %s", text)
+	}
+
+	// the output of --json can be read back by the publish command
+	bytes, err := json.Marshal(res)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded blocksResult
+	if err = json.Unmarshal(bytes, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded.Blocks) != 1 {
+		t.Fatalf("expected 1 block, got: %d", len(decoded.Blocks))
+	}
+	if blk := decoded.Blocks[0]; blk.Type != "open" || blk.Hash != genesis.LiveBlock.Hash() || !blk.Block.Valid() {
+		t.Fatalf("unexpected block: %+v", blk)
+	}
+}
+
+func TestBlockCreatorPrecompute(t *testing.T) {
+	dir, cleanup := testDir(t)
+	defer cleanup()
+
+	var seed wallet.Seed
+	copy(seed[:], "gonano test seed, do not use!!!!")
+	w, err := wallet.New(&seed, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = w.ChangePassword("password"); err != nil {
+		t.Fatal(err)
+	}
+	account := w.Accounts()[0]
+
+	newCreator := func(args ...string) *blockCreator {
+		flags := flag.NewFlagSet("test", flag.ContinueOnError)
+		blockFlags := newBlockFlags(flags)
+		if err := parseFlags(flags, args); err != nil {
+			t.Fatal(err)
+		}
+
+		opts := &options{wallet: filepath.Join(dir, "wallet.json")}
+		creator := newBlockCreator(opts, blockFlags, w, account)
+		creator.gen.Threshold = testWorkThreshold
+		creator.add(genesis.LiveBlock)
+		return creator
+	}
+
+	exists := func() bool {
+		_, err := os.Stat(filepath.Join(dir, "wallet.json"))
+		if err != nil && !os.IsNotExist(err) {
+			t.Fatal(err)
+		}
+		return err == nil
+	}
+
+	// work is not precomputed unless requested
+	creator := newCreator()
+	res, err := creator.finish()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Blocks) != 1 {
+		t.Fatalf("expected 1 block, got: %d", len(res.Blocks))
+	}
+	if err = creator.precompute(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if exists() {
+		t.Fatalf("wallet was saved without precomputing work")
+	}
+
+	// an interrupt skips the precomputation
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err = newCreator("-precompute").precompute(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if exists() {
+		t.Fatalf("wallet was saved after an interrupt")
+	}
+
+	if err = newCreator("-precompute").precompute(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := wallet.Load(filepath.Join(dir, "wallet.json"), "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := loaded.Account(account.Address()).Work(genesis.LiveBlock.Hash()); !ok {
+		t.Fatalf("precomputed work was not saved in the wallet")
+	}
+}
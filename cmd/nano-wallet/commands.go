@@ -0,0 +1,294 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/alexbakker/gonano/nano/wallet"
+)
+
+type walletResult struct {
+	Seed     string    `json:"seed,omitempty"`
+	Mnemonic string    `json:"mnemonic,omitempty"`
+	Accounts []address `json:"accounts"`
+}
+
+func (r *walletResult) text() string {
+	var lines []string
+	if r.Seed != "" {
+		lines = append(lines, fmt.Sprintf("seed: %s", r.Seed))
+	}
+	if r.Mnemonic != "" {
+		lines = append(lines, fmt.Sprintf("mnemonic: %s", r.Mnemonic))
+	}
+	for _, address := range r.Accounts {
+		lines = append(lines, address.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+type accountBalance struct {
+	Address address        `json:"account"`
+	Opened  bool           `json:"opened"`
+	Balance wallet.Balance `json:"balance"`
+	Raw     string         `json:"balance_raw"`
+}
+
+type balanceResult struct {
+	Accounts []*accountBalance `json:"accounts"`
+}
+
+func (r *balanceResult) text() string {
+	var lines []string
+	for _, account := range r.Accounts {
+		lines = append(lines, fmt.Sprintf("%s %s Mxrb", account.Address, account.Balance))
+	}
+	return strings.Join(lines, "\n")
+}
+
+type accountPending struct {
+	Address address         `json:"account"`
+	Blocks  []*pendingBlock `json:"blocks"`
+}
+
+type pendingResult struct {
+	Accounts []*accountPending `json:"accounts"`
+}
+
+func (r *pendingResult) text() string {
+	var lines []string
+	for _, account := range r.Accounts {
+		for _, blk := range account.Blocks {
+			lines = append(lines, fmt.Sprintf("%s: %s Mxrb from %s (%s)", account.Address, blk.Amount, blk.Source, blk.Hash))
+		}
+	}
+	if len(lines) == 0 {
+		return "no pending blocks"
+	}
+	return strings.Join(lines, "\n")
+}
+
+// derivationFlags contains the flags that select the key derivation of a new
+// wallet.
+type derivationFlags struct {
+	derivation *string
+	passphrase *string
+}
+
+func newDerivationFlags(flags *flag.FlagSet) *derivationFlags {
+	return &derivationFlags{
+		derivation: flags.String("derivation", wallet.DerivationLegacy.String(), "the key derivation of the wallet (legacy or bip44)"),
+		passphrase: flags.String("passphrase", "", "the BIP39 passphrase for bip44 key derivation"),
+	}
+}
+
+func (f *derivationFlags) newWallet(seed *wallet.Seed, index uint32) (*wallet.Wallet, error) {
+	derivation, err := wallet.ParseDerivation(*f.derivation)
+	if err != nil {
+		return nil, err
+	}
+
+	return wallet.NewWithDerivation(seed, index, derivation, *f.passphrase)
+}
+
+func newWalletResult(opts *options, w *wallet.Wallet, seed bool) *walletResult {
+	res := &walletResult{Accounts: []address{}}
+	if seed {
+		res.Seed = w.Seed().String()
+		res.Mnemonic = w.Seed().Mnemonic()
+	}
+	for _, account := range w.Accounts() {
+		res.Accounts = append(res.Accounts, opts.address(account.Address()))
+	}
+	return res
+}
+
+func runCreate(args []string) error {
+	var opts options
+	flags := newFlagSet("create", &opts)
+	derivation := newDerivationFlags(flags)
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	seed, err := wallet.GenerateSeed()
+	if err != nil {
+		return err
+	}
+
+	w, err := derivation.newWallet(seed, 0)
+	if err != nil {
+		return err
+	}
+	if err = opts.saveNewWallet(w); err != nil {
+		return err
+	}
+
+	opts.print(newWalletResult(&opts, w, true))
+	return nil
+}
+
+func runRestore(args []string) error {
+	var opts options
+	flags := newFlagSet("restore", &opts)
+	seedString := flags.String("seed", "", "the hex encoded seed to restore")
+	mnemonic := flags.String("mnemonic", "", "the BIP39 mnemonic of the seed to restore, instead of --seed")
+	index := flags.Uint("index", 0, "the index of the last account to restore")
+	derivation := newDerivationFlags(flags)
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	var seed *wallet.Seed
+	var err error
+	if *mnemonic != "" {
+		seed, err = wallet.ParseMnemonic(*mnemonic)
+	} else {
+		seed, err = wallet.ParseSeed(*seedString)
+	}
+	if err != nil {
+		return err
+	}
+
+	w, err := derivation.newWallet(seed, uint32(*index))
+	if err != nil {
+		return err
+	}
+	if err = opts.saveNewWallet(w); err != nil {
+		return err
+	}
+
+	opts.print(newWalletResult(&opts, w, false))
+	return nil
+}
+
+func runAccounts(args []string) error {
+	if len(args) == 0 || (args[0] != "list" && args[0] != "new") {
+		return fmt.Errorf("usage: nano-wallet accounts list|new [flags]")
+	}
+
+	var opts options
+	if err := parseFlags(newFlagSet("accounts "+args[0], &opts), args[1:]); err != nil {
+		return err
+	}
+
+	w, err := opts.loadWallet()
+	if err != nil {
+		return err
+	}
+
+	if args[0] == "list" {
+		opts.print(newWalletResult(&opts, w, false))
+		return nil
+	}
+
+	account, err := w.NewAccount()
+	if err != nil {
+		return err
+	}
+	if err = w.Save(opts.wallet); err != nil {
+		return err
+	}
+
+	opts.print(&walletResult{Accounts: []address{opts.address(account.Address())}})
+	return nil
+}
+
+// selectAccounts returns the accounts of the given wallet, or only the account
+// with the given address if it's not empty.
+func selectAccounts(w *wallet.Wallet, address string) ([]*wallet.Account, error) {
+	if address == "" {
+		return w.Accounts(), nil
+	}
+
+	account, err := findAccount(w, address)
+	if err != nil {
+		return nil, err
+	}
+	return []*wallet.Account{account}, nil
+}
+
+func runBalance(args []string) error {
+	var opts options
+	flags := newFlagSet("balance", &opts)
+	dbDir := flags.String("db", defaultDBDir(), "the ledger database")
+	address := flags.String("account", "", "only show the balance of this account")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	w, err := opts.loadWallet()
+	if err != nil {
+		return err
+	}
+	accounts, err := selectAccounts(w, *address)
+	if err != nil {
+		return err
+	}
+
+	db, err := openLedger(*dbDir)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	res := &balanceResult{Accounts: []*accountBalance{}}
+	for _, account := range accounts {
+		info, err := accountInfo(db, account.Address())
+		if err != nil {
+			return err
+		}
+
+		balance := &accountBalance{Address: opts.address(account.Address()), Balance: wallet.ZeroBalance}
+		if info != nil {
+			balance.Opened = true
+			balance.Balance = info.Balance
+		}
+		balance.Raw = balance.Balance.UnitString("raw", 0)
+		res.Accounts = append(res.Accounts, balance)
+	}
+
+	opts.print(res)
+	return nil
+}
+
+func runPending(args []string) error {
+	var opts options
+	flags := newFlagSet("pending", &opts)
+	dbDir := flags.String("db", defaultDBDir(), "the ledger database")
+	address := flags.String("account", "", "only show the pending blocks of this account")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	w, err := opts.loadWallet()
+	if err != nil {
+		return err
+	}
+	accounts, err := selectAccounts(w, *address)
+	if err != nil {
+		return err
+	}
+
+	db, err := openLedger(*dbDir)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	res := &pendingResult{Accounts: []*accountPending{}}
+	for _, account := range accounts {
+		blocks, err := pendingBlocks(db, account.Address(), opts.prefix)
+		if err != nil {
+			return err
+		}
+		if blocks == nil {
+			blocks = []*pendingBlock{}
+		}
+		res.Accounts = append(res.Accounts, &accountPending{Address: opts.address(account.Address()), Blocks: blocks})
+	}
+
+	opts.print(res)
+	return nil
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"path"
+
+	"github.com/alexbakker/gonano/nano/block"
+	"github.com/alexbakker/gonano/nano/store"
+	"github.com/alexbakker/gonano/nano/store/genesis"
+	"github.com/alexbakker/gonano/nano/wallet"
+)
+
+// pendingBlock is a send block that is waiting to be received.
+type pendingBlock struct {
+	Hash   block.Hash     `json:"hash"`
+	Source address        `json:"source"`
+	Amount wallet.Balance `json:"amount"`
+}
+
+// ledgerDB is a ledger together with the database it is stored in.
+type ledgerDB struct {
+	*store.Ledger
+	db store.Store
+}
+
+func defaultDBDir() string {
+	return path.Join(configDir(), "db")
+}
+
+// openLedger opens the ledger database in the given directory. The database
+// has to exist already and is neither initialized nor migrated, so that the
+// wallet doesn't write to the database of a node. The database of a node can't
+// be used while the node is running, but a copy of it can.
+func openLedger(dir string) (*ledgerDB, error) {
+	db, err := store.OpenBadgerStore(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	ledger, err := store.NewLedger(db, store.LedgerOptions{
+		GenesisBlock:   genesis.LiveBlock,
+		GenesisBalance: genesis.LiveBalance,
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &ledgerDB{Ledger: ledger, db: db}, nil
+}
+
+// Close closes the database of the ledger.
+func (l *ledgerDB) Close() error {
+	return l.db.Close()
+}
+
+// accountInfo returns the ledger information of the account with the given
+// address, or nil if the account hasn't been opened yet.
+func accountInfo(ledger *ledgerDB, address wallet.Address) (*store.AccountInfo, error) {
+	info, err := ledger.AccountInfo(address)
+	if err == store.ErrMissingAccount {
+		return nil, nil
+	}
+	return info, err
+}
+
+// pendingBlocks returns the send blocks that are waiting to be received by the
+// account with the given address. The addresses of the senders are printed with
+// the given prefix.
+func pendingBlocks(ledger *ledgerDB, account wallet.Address, prefix string) ([]*pendingBlock, error) {
+	blocks, err := ledger.Pending(account, wallet.ZeroBalance)
+	if err != nil {
+		return nil, err
+	}
+
+	var res []*pendingBlock
+	for _, blk := range blocks {
+		res = append(res, &pendingBlock{
+			Hash:   blk.Hash,
+			Source: address{address: blk.Address, prefix: prefix},
+			Amount: blk.Amount,
+		})
+	}
+
+	return res, nil
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/alexbakker/gonano/nano/store"
+	"github.com/alexbakker/gonano/nano/store/genesis"
+	"github.com/alexbakker/gonano/nano/wallet"
+)
+
+func testDir(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "nano-wallet")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return dir, func() {
+		os.RemoveAll(dir)
+	}
+}
+
+func TestOpenLedger(t *testing.T) {
+	dir, cleanup := testDir(t)
+	defer cleanup()
+
+	// the wallet doesn't create or initialize databases
+	missing := filepath.Join(dir, "missing")
+	if _, err := openLedger(missing); !os.IsNotExist(err) {
+		t.Fatalf("expected a not exist error, got: %v", err)
+	}
+	if _, err := os.Stat(missing); !os.IsNotExist(err) {
+		t.Fatalf("missing database directory was created")
+	}
+	if _, err := openLedger(dir); err != store.ErrStoreEmpty {
+		t.Fatalf("expected: %s, got: %v", store.ErrStoreEmpty, err)
+	}
+
+	// initialize the database like a node would
+	db, err := store.NewBadgerStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = store.NewLedger(db, store.LedgerOptions{
+		GenesisBlock:   genesis.LiveBlock,
+		GenesisBalance: genesis.LiveBalance,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	ledger, err := openLedger(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ledger.Close()
+
+	info, err := accountInfo(ledger, genesis.LiveBlock.Address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info == nil || info.HeadBlock != genesis.LiveBlock.Hash() {
+		t.Fatalf("unexpected account info for the genesis account: %+v", info)
+	}
+	if !info.Balance.Equal(genesis.LiveBalance) {
+		t.Fatalf("unexpected balance: %s", info.Balance)
+	}
+
+	unopened := make(wallet.Address, wallet.AddressSize)
+	if info, err = accountInfo(ledger, unopened); err != nil {
+		t.Fatal(err)
+	}
+	if info != nil {
+		t.Fatalf("expected no account info for an account that wasn't opened")
+	}
+
+	pending, err := pendingBlocks(ledger, unopened, wallet.AddressPrefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 0 {
+		t.Fatalf("expected no pending blocks, got: %d", len(pending))
+	}
+}
@@ -1,5 +1,266 @@
 package main
 
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"path"
+	"strings"
+
+	"github.com/alexbakker/gonano/nano/wallet"
+)
+
+const (
+	passwordEnv = "GONANO_WALLET_PASSWORD"
+)
+
+var (
+	ErrNoAccount   = errors.New("account is not part of the wallet")
+	ErrUnknownArgs = errors.New("unexpected arguments")
+
+	commands = []*command{
+		{"create", "create a new wallet with a random seed", runCreate},
+		{"restore", "restore a wallet from an existing seed", runRestore},
+		{"accounts", "list the accounts of the wallet (list) or add a new one (new)", runAccounts},
+		{"balance", "show the balance of the accounts of the wallet", runBalance},
+		{"pending", "show the blocks that are waiting to be received", runPending},
+		{"send", "create a block that sends funds to another account", runSend},
+		{"receive", "create blocks that receive pending funds", runReceive},
+		{"change-rep", "create a block that changes the representative of an account", runChangeRep},
+		{"publish", "publish a block that was created with --json", runPublish},
+	}
+)
+
+type command struct {
+	name  string
+	usage string
+	run   func(args []string) error
+}
+
+// options contains the flags that are shared by all commands.
+type options struct {
+	wallet   string
+	password string
+	json     bool
+	prefix   string
+}
+
+// result is the output of a command. It's either encoded as JSON or printed
+// as text, depending on the --json flag.
+type result interface {
+	text() string
+}
+
+type errorResult struct {
+	Error string `json:"error"`
+}
+
+func (r *errorResult) text() string {
+	return fmt.Sprintf("error: %s", r.Error)
+}
+
+func configDir() string {
+	user, err := user.Current()
+	if err != nil {
+		panic(err)
+	}
+
+	return path.Join(user.HomeDir, ".config/gonano")
+}
+
+// prefixFlag is a flag that sets the prefix addresses are printed with.
+type prefixFlag struct {
+	prefix *string
+}
+
+func (f prefixFlag) String() string {
+	if f.prefix == nil {
+		return ""
+	}
+	return *f.prefix
+}
+
+func (f prefixFlag) Set(s string) error {
+	if s != wallet.AddressPrefix && s != wallet.AddressPrefixNano {
+		return wallet.ErrAddressPrefix
+	}
+
+	*f.prefix = s
+	return nil
+}
+
+// address is an address that is printed with the prefix of the
+// --address-prefix flag, both as text and as JSON.
+type address struct {
+	address wallet.Address
+	prefix  string
+}
+
+func (a address) String() string {
+	return a.address.StringWithPrefix(a.prefix)
+}
+
+// MarshalText implements the encoding.TextMarshaler interface.
+func (a address) MarshalText() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+// address returns the given address with the prefix of the --address-prefix
+// flag.
+func (o *options) address(a wallet.Address) address {
+	return address{address: a, prefix: o.prefix}
+}
+
+func newFlagSet(name string, opts *options) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+	opts.prefix = wallet.AddressPrefix
+	flags.Var(prefixFlag{&opts.prefix}, "address-prefix", "the prefix to print account addresses with (xrb_ or nano_), blocks always use xrb_")
+	flags.StringVar(&opts.wallet, "wallet", path.Join(configDir(), "wallet.json"), "the wallet file")
+	flags.StringVar(&opts.password, "password", "", "the password of the wallet (defaults to $"+passwordEnv+" or a prompt)")
+	flags.BoolVar(&opts.json, "json", false, "print the output as JSON")
+	return flags
+}
+
+// parseFlags parses the given arguments and makes sure no positional arguments
+// are left.
+func parseFlags(flags *flag.FlagSet, args []string) error {
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 0 {
+		return ErrUnknownArgs
+	}
+	return nil
+}
+
+// readPassword returns the password from the --password flag, the environment
+// or a prompt, in that order.
+func (o *options) readPassword() (string, error) {
+	if o.password != "" {
+		return o.password, nil
+	}
+	if password := os.Getenv(passwordEnv); password != "" {
+		return password, nil
+	}
+
+	fmt.Fprint(os.Stderr, "password: ")
+	password, err := readSecret(os.Stdin)
+	// the newline that was typed wasn't echoed
+	fmt.Fprintln(os.Stderr)
+	return password, err
+}
+
+// readLine reads a single line from the given reader, without the line ending.
+func readLine(r io.Reader) (string, error) {
+	line, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func (o *options) loadWallet() (*wallet.Wallet, error) {
+	password, err := o.readPassword()
+	if err != nil {
+		return nil, err
+	}
+
+	return wallet.Load(o.wallet, password)
+}
+
+// saveNewWallet sets the password of the given wallet and saves it. It refuses to
+// overwrite an existing wallet file.
+func (o *options) saveNewWallet(w *wallet.Wallet) error {
+	if _, err := os.Stat(o.wallet); err == nil {
+		return fmt.Errorf("wallet file %s already exists", o.wallet)
+	}
+
+	password, err := o.readPassword()
+	if err != nil {
+		return err
+	}
+	if err = w.ChangePassword(password); err != nil {
+		return err
+	}
+
+	if err = os.MkdirAll(path.Dir(o.wallet), 0700); err != nil {
+		return err
+	}
+	return w.Save(o.wallet)
+}
+
+// findAccount returns the account of the given wallet with the given address.
+func findAccount(w *wallet.Wallet, s string) (*wallet.Account, error) {
+	address, err := wallet.ParseAddress(s)
+	if err != nil {
+		return nil, err
+	}
+
+	account := w.Account(address)
+	if account == nil {
+		return nil, ErrNoAccount
+	}
+	return account, nil
+}
+
+// print prints the given result of a command.
+func (o *options) print(res result) {
+	if !o.json {
+		fmt.Println(res.text())
+		return
+	}
+
+	bytes, err := json.MarshalIndent(res, "", "\t")
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(string(bytes))
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: nano-wallet <command> [flags]\n\ncommands:\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-12s %s\n", cmd.name, cmd.usage)
+	}
+	fmt.Fprintf(os.Stderr, "\nrun nano-wallet <command> --help for the flags of a command\n")
+}
+
+// hasJSONFlag reports whether the given arguments request JSON output. It's
+// used to format errors that occur before or during flag parsing.
+func hasJSONFlag(args []string) bool {
+	for _, arg := range args {
+		if arg == "-json" || arg == "--json" || arg == "-json=true" || arg == "--json=true" {
+			return true
+		}
+	}
+	return false
+}
+
 func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	name, args := os.Args[1], os.Args[2:]
+	for _, cmd := range commands {
+		if cmd.name != name {
+			continue
+		}
+
+		if err := cmd.run(args); err != nil {
+			opts := options{json: hasJSONFlag(args)}
+			opts.print(&errorResult{Error: err.Error()})
+			os.Exit(1)
+		}
+		return
+	}
 
+	usage()
+	os.Exit(2)
 }
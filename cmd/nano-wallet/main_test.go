@@ -0,0 +1,52 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/alexbakker/gonano/nano/wallet"
+)
+
+func TestAddressPrefix(t *testing.T) {
+	s := "xrb_3t6k35gi95xu6tergt6p69ck76ogmitsa8mnijtpxm9fkcm736xtoncuohr3"
+	account, err := wallet.ParseAddress(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var opts options
+	flags := newFlagSet("test", &opts)
+	if err = parseFlags(flags, nil); err != nil {
+		t.Fatal(err)
+	}
+	if addr := opts.address(account).String(); addr != s {
+		t.Fatalf("unexpected address: %s", addr)
+	}
+
+	if err = parseFlags(flags, []string{"-address-prefix", "nano_"}); err != nil {
+		t.Fatal(err)
+	}
+	res := &walletResult{Accounts: []address{opts.address(account)}}
+	expected := "nano_" + s[len(wallet.AddressPrefix):]
+	if text := res.text(); text != expected {
+		t.Fatalf("unexpected text: %s", text)
+	}
+
+	bytes, err := json.Marshal(res)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bytes) != `{"accounts":["`+expected+`"]}` {
+		t.Fatalf("unexpected json: %s", bytes)
+	}
+
+	// the prefix only applies to the output of the command
+	if account.String() != s {
+		t.Fatalf("default prefix was changed: %s", account)
+	}
+
+	flag := prefixFlag{&opts.prefix}
+	if err = flag.Set("nan_"); err != wallet.ErrAddressPrefix {
+		t.Fatalf("expected: %s, got: %v", wallet.ErrAddressPrefix, err)
+	}
+}
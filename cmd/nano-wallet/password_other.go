@@ -0,0 +1,15 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+// readSecret is not supported on this platform, because echo can't be turned
+// off. The password has to be passed with the flag or the environment instead.
+func readSecret(f *os.File) (string, error) {
+	return "", errors.New("can't prompt for the password on this platform, use --password or $" + passwordEnv)
+}
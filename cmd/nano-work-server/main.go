@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/alexbakker/gonano/nano/block"
+)
+
+const (
+	queueSize = 1024
+)
+
+var (
+	ErrBadAction     = errors.New("unknown action")
+	ErrBadDifficulty = errors.New("bad difficulty")
+	ErrBadMethod     = errors.New("only POST requests are supported")
+)
+
+type request struct {
+	Action     string      `json:"action"`
+	Hash       block.Hash  `json:"hash"`
+	Work       *block.Work `json:"work"`
+	Difficulty string      `json:"difficulty"`
+}
+
+type generateResponse struct {
+	Hash       block.Hash `json:"hash"`
+	Work       block.Work `json:"work"`
+	Difficulty string     `json:"difficulty"`
+	Multiplier string     `json:"multiplier"`
+}
+
+type validateResponse struct {
+	Valid      string `json:"valid"`
+	Difficulty string `json:"difficulty"`
+	Multiplier string `json:"multiplier"`
+}
+
+type cancelResponse struct {
+	Success string `json:"success"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+type server struct {
+	queue     *workQueue
+	threshold uint64
+}
+
+func formatDifficulty(difficulty uint64) string {
+	return fmt.Sprintf("%016x", difficulty)
+}
+
+func formatMultiplier(difficulty uint64, base uint64) string {
+	return strconv.FormatFloat(block.Multiplier(difficulty, base), 'f', -1, 64)
+}
+
+// threshold returns the difficulty threshold of the given request, or the given
+// threshold of the network if the request doesn't specify one.
+func (r *request) threshold(network uint64) (uint64, error) {
+	if r.Difficulty == "" {
+		return network, nil
+	}
+
+	threshold, err := strconv.ParseUint(r.Difficulty, 16, 64)
+	if err != nil {
+		return 0, ErrBadDifficulty
+	}
+	return threshold, nil
+}
+
+func (s *server) handleGenerate(ctx context.Context, req *request) (interface{}, error) {
+	threshold, err := req.threshold(s.threshold)
+	if err != nil {
+		return nil, err
+	}
+
+	work, err := s.queue.generate(ctx, req.Hash, threshold)
+	if err != nil {
+		return nil, err
+	}
+
+	difficulty := work.Difficulty(req.Hash)
+
+	return &generateResponse{
+		Hash:       req.Hash,
+		Work:       work,
+		Difficulty: formatDifficulty(difficulty),
+		Multiplier: formatMultiplier(difficulty, s.threshold),
+	}, nil
+}
+
+func (s *server) handleValidate(req *request) (interface{}, error) {
+	threshold, err := req.threshold(s.threshold)
+	if err != nil {
+		return nil, err
+	}
+	if req.Work == nil {
+		return nil, errors.New("no work specified")
+	}
+
+	valid := "0"
+	if req.Work.ValidThreshold(req.Hash, threshold) {
+		valid = "1"
+	}
+
+	difficulty := req.Work.Difficulty(req.Hash)
+	return &validateResponse{
+		Valid:      valid,
+		Difficulty: formatDifficulty(difficulty),
+		Multiplier: formatMultiplier(difficulty, s.threshold),
+	}, nil
+}
+
+func (s *server) handleCancel(req *request) (interface{}, error) {
+	s.queue.cancel(req.Hash)
+	return &cancelResponse{}, nil
+}
+
+func (s *server) handleRequest(r *http.Request) (interface{}, error) {
+	if r.Method != http.MethodPost {
+		return nil, ErrBadMethod
+	}
+
+	var req request
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return nil, err
+	}
+
+	switch req.Action {
+	case "work_generate":
+		return s.handleGenerate(r.Context(), &req)
+	case "work_validate":
+		return s.handleValidate(&req)
+	case "work_cancel":
+		return s.handleCancel(&req)
+	default:
+		return nil, ErrBadAction
+	}
+}
+
+// ServeHTTP implements the http.Handler interface.
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	res, err := s.handleRequest(r)
+	if err != nil {
+		res = &errorResponse{Error: err.Error()}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(res); err != nil {
+		fmt.Printf("error writing response: %s\n", err)
+	}
+}
+
+func main() {
+	listen := flag.String("listen", "127.0.0.1:7076", "the address to listen on")
+	threads := flag.Int("threads", 0, "the amount of threads to use (defaults to the amount of CPUs)")
+	thresholdString := flag.String("threshold", "", "the work threshold of the network in hex (defaults to that of the live network)")
+	flag.Parse()
+
+	threshold := uint64(block.WorkThreshold)
+	if *thresholdString != "" {
+		value, err := strconv.ParseUint(*thresholdString, 16, 64)
+		if err != nil {
+			fmt.Printf("error: %s\n", ErrBadDifficulty)
+			return
+		}
+		threshold = value
+	}
+
+	queue := newWorkQueue(block.NewWorkGenerator(*threads), queueSize)
+	go queue.run(context.Background())
+
+	fmt.Printf("listening on %s\n", *listen)
+	if err := http.ListenAndServe(*listen, &server{queue: queue, threshold: threshold}); err != nil {
+		fmt.Printf("error: %s\n", err)
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/alexbakker/gonano/nano/block"
+)
+
+var (
+	ErrQueueFull = errors.New("work queue is full")
+)
+
+// workJob is a request to generate work for a root that meets a threshold.
+// Multiple requests for the same root can share a job. The job is canceled once
+// none of them are waiting for it anymore.
+type workJob struct {
+	root      block.Hash
+	threshold uint64
+	waiters   int
+	ctx       context.Context
+	cancel    context.CancelFunc
+	done      chan struct{}
+	work      block.Work
+	err       error
+}
+
+// workQueue generates work for queued jobs one at a time, so that every job
+// gets all of the available threads.
+type workQueue struct {
+	gen   *block.WorkGenerator
+	mutex sync.Mutex
+	jobs  map[block.Hash][]*workJob
+	queue chan *workJob
+}
+
+func newWorkQueue(gen *block.WorkGenerator, size int) *workQueue {
+	return &workQueue{
+		gen:   gen,
+		jobs:  make(map[block.Hash][]*workJob),
+		queue: make(chan *workJob, size),
+	}
+}
+
+// run processes the jobs in the queue until the given context is done.
+func (q *workQueue) run(ctx context.Context) {
+	for {
+		select {
+		case job := <-q.queue:
+			q.process(job)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (q *workQueue) process(job *workJob) {
+	if err := job.ctx.Err(); err != nil {
+		job.err = err
+	} else {
+		job.work, job.err = q.gen.GenerateThreshold(job.ctx, job.root, job.threshold)
+		if job.err == nil {
+			fmt.Printf("generated work for %s: %s (%.0f attempts/s)\n", job.root, job.work, q.gen.Rate())
+		}
+	}
+
+	q.mutex.Lock()
+	q.remove(job)
+	q.mutex.Unlock()
+
+	job.cancel()
+	close(job.done)
+}
+
+// generate waits for work for the given root that meets the given threshold.
+// If a job that satisfies the request is already queued or in progress, the
+// request waits for that job instead of queueing a new one.
+func (q *workQueue) generate(ctx context.Context, root block.Hash, threshold uint64) (block.Work, error) {
+	job, err := q.job(root, threshold)
+	if err != nil {
+		return 0, err
+	}
+
+	select {
+	case <-job.done:
+		return job.work, job.err
+	case <-ctx.Done():
+		q.leave(job)
+		return 0, ctx.Err()
+	}
+}
+
+func (q *workQueue) job(root block.Hash, threshold uint64) (*workJob, error) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	for _, job := range q.jobs[root] {
+		if job.threshold >= threshold {
+			job.waiters++
+			return job, nil
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	job := &workJob{
+		root:      root,
+		threshold: threshold,
+		waiters:   1,
+		ctx:       ctx,
+		cancel:    cancel,
+		done:      make(chan struct{}),
+	}
+
+	select {
+	case q.queue <- job:
+	default:
+		cancel()
+		return nil, ErrQueueFull
+	}
+
+	q.jobs[root] = append(q.jobs[root], job)
+	return job, nil
+}
+
+// leave stops waiting for the given job and cancels it if nobody else is
+// waiting for it.
+func (q *workQueue) leave(job *workJob) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	job.waiters--
+	if job.waiters == 0 {
+		q.remove(job)
+		job.cancel()
+	}
+}
+
+// cancel cancels all jobs for the given root.
+func (q *workQueue) cancel(root block.Hash) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	for _, job := range q.jobs[root] {
+		job.cancel()
+	}
+	delete(q.jobs, root)
+}
+
+// remove removes the given job from the jobs that new requests can share. The
+// mutex of the queue must be held.
+func (q *workQueue) remove(job *workJob) {
+	jobs := q.jobs[job.root]
+	for i, j := range jobs {
+		if j == job {
+			jobs = append(jobs[:i], jobs[i+1:]...)
+			break
+		}
+	}
+
+	if len(jobs) == 0 {
+		delete(q.jobs, job.root)
+	} else {
+		q.jobs[job.root] = jobs
+	}
+}
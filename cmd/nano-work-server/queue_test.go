@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alexbakker/gonano/nano/block"
+)
+
+const (
+	// testThreshold is low enough to generate work for tests quickly.
+	testThreshold = 0xff00000000000000
+	// impossibleThreshold can't be met, so jobs with it run until canceled.
+	impossibleThreshold = 0xffffffffffffffff
+)
+
+var (
+	testRoot  = block.Hash{1}
+	otherRoot = block.Hash{2}
+)
+
+func newTestQueue(size int) (*workQueue, func()) {
+	q := newWorkQueue(block.NewWorkGenerator(1), size)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		q.run(ctx)
+		close(done)
+	}()
+
+	return q, func() {
+		cancel()
+		<-done
+	}
+}
+
+// waitWaiters waits until the jobs for the given root have the given amount of
+// waiters in total.
+func waitWaiters(t *testing.T, q *workQueue, root block.Hash, waiters int) []*workJob {
+	for i := 0; i < 100; i++ {
+		q.mutex.Lock()
+		jobs := append([]*workJob(nil), q.jobs[root]...)
+		var n int
+		for _, job := range jobs {
+			n += job.waiters
+		}
+		q.mutex.Unlock()
+
+		if n == waiters {
+			return jobs
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Fatalf("expected %d waiters for %s", waiters, root)
+	return nil
+}
+
+// generateAsync calls generate in a new goroutine and returns a channel that
+// receives its error.
+func generateAsync(ctx context.Context, q *workQueue, root block.Hash, threshold uint64) chan error {
+	res := make(chan error, 1)
+	go func() {
+		_, err := q.generate(ctx, root, threshold)
+		res <- err
+	}()
+	return res
+}
+
+func TestQueueGenerate(t *testing.T) {
+	q, stop := newTestQueue(4)
+	defer stop()
+
+	work, err := q.generate(context.Background(), testRoot, testThreshold)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !work.ValidThreshold(testRoot, testThreshold) {
+		t.Fatalf("work not valid")
+	}
+
+	// finished jobs are not shared with new requests
+	if jobs := waitWaiters(t, q, testRoot, 0); len(jobs) != 0 {
+		t.Fatalf("finished job was not removed")
+	}
+}
+
+func TestQueueJob(t *testing.T) {
+	// the queue isn't run, so the jobs stay queued
+	q := newWorkQueue(block.NewWorkGenerator(1), 2)
+
+	job, err := q.job(testRoot, testThreshold)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// requests for the same root share a job if its threshold is high enough
+	for _, threshold := range []uint64{testThreshold, testThreshold - 1} {
+		res, err := q.job(testRoot, threshold)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res != job {
+			t.Fatalf("job with threshold %x was not shared", threshold)
+		}
+	}
+	if job.waiters != 3 {
+		t.Fatalf("expected 3 waiters, got: %d", job.waiters)
+	}
+
+	// a higher threshold needs a new job
+	res, err := q.job(testRoot, testThreshold+1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res == job {
+		t.Fatalf("job with a lower threshold was shared")
+	}
+
+	// the queue only has room for two jobs
+	if _, err = q.job(otherRoot, testThreshold); err != ErrQueueFull {
+		t.Fatalf("expected a full queue, got: %v", err)
+	}
+	if _, ok := q.jobs[otherRoot]; ok {
+		t.Fatalf("rejected job was added")
+	}
+	if len(q.queue) != 2 {
+		t.Fatalf("expected 2 queued jobs, got: %d", len(q.queue))
+	}
+}
+
+func TestQueueCancel(t *testing.T) {
+	q, stop := newTestQueue(4)
+	defer stop()
+
+	res := generateAsync(context.Background(), q, testRoot, impossibleThreshold)
+	waitWaiters(t, q, testRoot, 1)
+
+	q.cancel(testRoot)
+	if err := <-res; err != context.Canceled {
+		t.Fatalf("expected the job to be canceled, got: %v", err)
+	}
+
+	// new requests for the root don't wait for the canceled job
+	if _, err := q.generate(context.Background(), testRoot, testThreshold); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestQueueWaiters(t *testing.T) {
+	q, stop := newTestQueue(4)
+	defer stop()
+
+	ctx1, cancel1 := context.WithCancel(context.Background())
+	defer cancel1()
+	ctx2, cancel2 := context.WithCancel(context.Background())
+	defer cancel2()
+
+	res1 := generateAsync(ctx1, q, testRoot, impossibleThreshold)
+	res2 := generateAsync(ctx2, q, testRoot, impossibleThreshold)
+	jobs := waitWaiters(t, q, testRoot, 2)
+	if len(jobs) != 1 {
+		t.Fatalf("expected the requests to share a job, got %d jobs", len(jobs))
+	}
+	job := jobs[0]
+
+	// the job keeps running while somebody is waiting for it
+	cancel1()
+	if err := <-res1; err != context.Canceled {
+		t.Fatalf("expected the request to be canceled, got: %v", err)
+	}
+	if job.ctx.Err() != nil {
+		t.Fatalf("job was canceled while a request was still waiting for it")
+	}
+
+	// the job is canceled when the last request stops waiting
+	cancel2()
+	if err := <-res2; err != context.Canceled {
+		t.Fatalf("expected the request to be canceled, got: %v", err)
+	}
+	select {
+	case <-job.done:
+	case <-time.After(10 * time.Second):
+		t.Fatalf("job was not canceled")
+	}
+	if job.err != context.Canceled {
+		t.Fatalf("expected the job to be canceled, got: %v", job.err)
+	}
+}
@@ -17,6 +17,13 @@ const (
 	idBlockReceive
 	idBlockOpen
 	idBlockChange
+	idBlockState
+)
+
+const (
+	// IDNotABlock is the block type that is used to mark the end of a stream of
+	// blocks.
+	IDNotABlock = idBlockNotABlock
 )
 
 var (
@@ -30,6 +37,7 @@ var (
 		idBlockReceive:   "receive",
 		idBlockOpen:      "open",
 		idBlockChange:    "change",
+		idBlockState:     "state",
 	}
 )
 
@@ -39,6 +47,14 @@ const (
 	blockSizeSend    = blockSizeCommon + HashSize + wallet.AddressSize + 16
 	blockSizeReceive = blockSizeCommon + HashSize*2
 	blockSizeChange  = blockSizeCommon + HashSize + wallet.AddressSize
+	blockSizeState   = blockSizeCommon + HashSize*2 + wallet.AddressSize*2 + wallet.BalanceSize
+)
+
+var (
+	// statePreamble is prepended to the fields of a state block when hashing it,
+	// so that the hash of a state block can't collide with that of a legacy
+	// block.
+	statePreamble = Hash{31: idBlockState}
 )
 
 type CommonBlock struct {
@@ -55,6 +71,7 @@ type Block interface {
 	Size() int
 	ID() byte
 	Valid() bool
+	ValidThreshold(threshold uint64) bool
 }
 
 type OpenBlock struct {
@@ -83,6 +100,20 @@ type ChangeBlock struct {
 	Common         CommonBlock    `json:"common"`
 }
 
+// StateBlock is a block that contains the full state of an account. Whether it
+// sends, receives, opens or changes the representative is derived from the
+// balance of the previous block. For sends, the link field contains the
+// destination address. For receives and opens, it contains the hash of the
+// source block.
+type StateBlock struct {
+	Address        wallet.Address `json:"account"`
+	PreviousHash   Hash           `json:"previous"`
+	Representative wallet.Address `json:"representative"`
+	Balance        wallet.Balance `json:"balance"`
+	Link           Hash           `json:"link"`
+	Common         CommonBlock    `json:"common"`
+}
+
 func New(blockType byte) (Block, error) {
 	switch blockType {
 	case idBlockOpen:
@@ -93,6 +124,8 @@ func New(blockType byte) (Block, error) {
 		return new(ReceiveBlock), nil
 	case idBlockChange:
 		return new(ChangeBlock), nil
+	case idBlockState:
+		return new(StateBlock), nil
 	case idBlockNotABlock:
 		return nil, ErrNotABlock
 	default:
@@ -104,6 +137,17 @@ func Name(id byte) string {
 	return blockNames[id]
 }
 
+// NewFromName creates a new block of the type with the given name.
+func NewFromName(name string) (Block, error) {
+	for id, blockName := range blockNames {
+		if blockName == name {
+			return New(id)
+		}
+	}
+
+	return nil, ErrBadBlockType
+}
+
 // MarshalBinary implements the encoding.BinaryMarshaler interface.
 func (b *CommonBlock) MarshalBinary() ([]byte, error) {
 	buf := new(bytes.Buffer)
@@ -211,9 +255,13 @@ func (b *OpenBlock) ID() byte {
 }
 
 func (b *OpenBlock) Valid() bool {
+	return b.ValidThreshold(WorkThreshold)
+}
+
+func (b *OpenBlock) ValidThreshold(threshold uint64) bool {
 	var hash Hash
 	copy(hash[:], b.Address)
-	return b.Common.Work.Valid(hash)
+	return b.Common.Work.ValidThreshold(hash, threshold)
 }
 
 // MarshalBinary implements the encoding.BinaryMarshaler interface.
@@ -295,7 +343,11 @@ func (b *SendBlock) ID() byte {
 }
 
 func (b *SendBlock) Valid() bool {
-	return b.Common.Work.Valid(b.PreviousHash)
+	return b.ValidThreshold(WorkThreshold)
+}
+
+func (b *SendBlock) ValidThreshold(threshold uint64) bool {
+	return b.Common.Work.ValidThreshold(b.PreviousHash, threshold)
 }
 
 // MarshalBinary implements the encoding.BinaryMarshaler interface.
@@ -364,7 +416,11 @@ func (b *ReceiveBlock) ID() byte {
 }
 
 func (b *ReceiveBlock) Valid() bool {
-	return b.Common.Work.Valid(b.PreviousHash)
+	return b.ValidThreshold(WorkThreshold)
+}
+
+func (b *ReceiveBlock) ValidThreshold(threshold uint64) bool {
+	return b.Common.Work.ValidThreshold(b.PreviousHash, threshold)
 }
 
 // MarshalBinary implements the encoding.BinaryMarshaler interface.
@@ -434,5 +490,128 @@ func (b *ChangeBlock) ID() byte {
 }
 
 func (b *ChangeBlock) Valid() bool {
-	return b.Common.Work.Valid(b.PreviousHash)
+	return b.ValidThreshold(WorkThreshold)
+}
+
+func (b *ChangeBlock) ValidThreshold(threshold uint64) bool {
+	return b.Common.Work.ValidThreshold(b.PreviousHash, threshold)
+}
+
+// MarshalBinary implements the encoding.BinaryMarshaler interface. Unlike the
+// other block types, the work value of a state block is encoded in big endian.
+func (b *StateBlock) MarshalBinary() ([]byte, error) {
+	buf := new(bytes.Buffer)
+
+	var err error
+	if _, err = buf.Write(b.Address); err != nil {
+		return nil, err
+	}
+
+	if _, err = buf.Write(b.PreviousHash[:]); err != nil {
+		return nil, err
+	}
+
+	if _, err = buf.Write(b.Representative); err != nil {
+		return nil, err
+	}
+
+	if _, err = buf.Write(b.Balance.Bytes(binary.BigEndian)); err != nil {
+		return nil, err
+	}
+
+	if _, err = buf.Write(b.Link[:]); err != nil {
+		return nil, err
+	}
+
+	if _, err = buf.Write(b.Common.Signature[:]); err != nil {
+		return nil, err
+	}
+
+	if err = binary.Write(buf, binary.BigEndian, b.Common.Work); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary implements the encoding.BinaryUnmarshaler interface.
+func (b *StateBlock) UnmarshalBinary(data []byte) error {
+	reader := bytes.NewReader(data)
+
+	var err error
+	b.Address = make([]byte, wallet.AddressSize)
+	if _, err = reader.Read(b.Address); err != nil {
+		return err
+	}
+
+	if _, err = reader.Read(b.PreviousHash[:]); err != nil {
+		return err
+	}
+
+	b.Representative = make([]byte, wallet.AddressSize)
+	if _, err = reader.Read(b.Representative); err != nil {
+		return err
+	}
+
+	balance := make([]byte, wallet.BalanceSize)
+	if _, err = reader.Read(balance); err != nil {
+		return err
+	}
+	if err = b.Balance.UnmarshalBinary(balance); err != nil {
+		return err
+	}
+
+	if _, err = reader.Read(b.Link[:]); err != nil {
+		return err
+	}
+
+	if _, err = reader.Read(b.Common.Signature[:]); err != nil {
+		return err
+	}
+
+	if err = binary.Read(reader, binary.BigEndian, &b.Common.Work); err != nil {
+		return err
+	}
+
+	return util.AssertReaderEOF(reader)
+}
+
+func (b *StateBlock) Hash() Hash {
+	return hashBytes(statePreamble[:], b.Address, b.PreviousHash[:], b.Representative, b.Balance.Bytes(binary.BigEndian), b.Link[:])
+}
+
+// Root returns the hash of the previous block, or the address of the account if
+// this block opens it.
+func (b *StateBlock) Root() Hash {
+	if b.IsOpen() {
+		var root Hash
+		copy(root[:], b.Address)
+		return root
+	}
+	return b.PreviousHash
+}
+
+func (b *StateBlock) Signature() Signature {
+	return b.Common.Signature
+}
+
+func (b *StateBlock) Size() int {
+	return blockSizeState
+}
+
+func (b *StateBlock) ID() byte {
+	return idBlockState
+}
+
+func (b *StateBlock) Valid() bool {
+	return b.ValidThreshold(WorkThreshold)
+}
+
+func (b *StateBlock) ValidThreshold(threshold uint64) bool {
+	return b.Common.Work.ValidThreshold(b.Root(), threshold)
+}
+
+// IsOpen reports whether this is the first block of an account chain.
+func (b *StateBlock) IsOpen() bool {
+	return b.PreviousHash.IsZero()
 }
@@ -1,6 +1,8 @@
 package block
 
 import (
+	"bytes"
+	"encoding/json"
 	"testing"
 
 	"github.com/alexbakker/gonano/nano/internal/util"
@@ -42,6 +44,17 @@ var (
 			Signature: util.MustDecodeHex64("1009a0c2fbc189dc41d13daa9d7a6e1ab2d6c4e06200aeca1b7ae0c27bf454b2c60f446df0d69a877d3a98e3128ab3442058172fbd965024519630ace93b670e"),
 		},
 	}
+	stateBlock = &StateBlock{
+		Address:        util.MustDecodeHex("e404a282405282dcbf38a04c85dcb1ccfef72f014ee2587599fcb6d7e3f34d6f"),
+		PreviousHash:   util.MustDecodeHex32("838f973e92995bd18ebeebf13014c745604d1cfa1b9435f44b51e2a796bd656c"),
+		Representative: util.MustDecodeHex("f62d724239fe2c9d5691f6a9a7bf496438e75e7b3e55dc012cfedfe5250fc4bb"),
+		Balance:        wallet.ParseBalanceInts(0, 40),
+		Link:           util.MustDecodeHex32("f62d724239fe2c9d5691f6a9a7bf496438e75e7b3e55dc012cfedfe5250fc4bb"),
+		Common: CommonBlock{
+			Work:      0x000000000286c03c,
+			Signature: util.MustDecodeHex64("a1843d0c2fa89dbec37164b76ebad63794c32ee33c4b7103b024ae3af4f9e251a34a58a5deac33b69ba23f1b4c27b2cab551f2409f07508879021d7a86bc220f"),
+		},
+	}
 )
 
 func TestBlockOpenMarshal(t *testing.T) {
@@ -107,3 +120,57 @@ func TestBlockChangeMarshal(t *testing.T) {
 		t.Fatalf("blocks not equal")
 	}
 }
+
+func TestBlockStateMarshal(t *testing.T) {
+	bytes, err := stateBlock.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bytes) != stateBlock.Size() {
+		t.Fatalf("unexpected block size: %d", len(bytes))
+	}
+
+	var blk StateBlock
+	if err = blk.UnmarshalBinary(bytes); err != nil {
+		t.Fatal(err)
+	}
+
+	if blk.Hash() != stateBlock.Hash() || blk.Common != stateBlock.Common {
+		t.Fatalf("blocks not equal")
+	}
+}
+
+func TestBlockState(t *testing.T) {
+	hash := util.MustDecodeHex32("375e06a255380b8b2bcdb3049f05f1219fc4a21442e598ae7b1f9d94bb6a1bbd")
+	if stateBlock.Hash() != hash {
+		t.Fatalf("unexpected hash: %s", stateBlock.Hash())
+	}
+
+	if stateBlock.Root() != stateBlock.PreviousHash {
+		t.Fatalf("unexpected root: %s", stateBlock.Root())
+	}
+	if !stateBlock.Valid() {
+		t.Fatalf("work is not valid")
+	}
+
+	// the root of a state block that opens an account is the account address
+	openState := *stateBlock
+	openState.PreviousHash = Hash{}
+	root := openState.Root()
+	if !openState.IsOpen() || !bytes.Equal(root[:], openState.Address) {
+		t.Fatalf("unexpected root: %s", root)
+	}
+
+	data, err := json.Marshal(stateBlock)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var blk StateBlock
+	if err = json.Unmarshal(data, &blk); err != nil {
+		t.Fatal(err)
+	}
+	if blk.Hash() != stateBlock.Hash() || blk.Common != stateBlock.Common {
+		t.Fatalf("blocks not equal")
+	}
+}
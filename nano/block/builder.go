@@ -0,0 +1,136 @@
+package block
+
+import (
+	"context"
+
+	"github.com/alexbakker/gonano/nano/wallet"
+)
+
+// Builder creates blocks for an account. The blocks are signed with the private
+// key of the account and, if enabled, proof of work is attached to them.
+type Builder struct {
+	account *wallet.Account
+
+	// Generator generates the proof of work that is attached to new blocks.
+	// Work that was precomputed for the account is used if there is any. If
+	// it's nil, no work is attached.
+	Generator *WorkGenerator
+
+	// Threshold is the minimum difficulty of the work that is attached to new
+	// blocks. It defaults to the threshold of the live network.
+	Threshold uint64
+
+	// Cache is notified of the new head of the account after every block, so
+	// that it can precompute work for the next one. It's optional.
+	Cache *WorkCache
+}
+
+// NewBuilder creates a new block builder for the given account that uses the
+// given generator for proof of work.
+func NewBuilder(account *wallet.Account, gen *WorkGenerator) *Builder {
+	return &Builder{
+		account:   account,
+		Generator: gen,
+		Threshold: WorkThreshold,
+	}
+}
+
+// Open creates a block that opens the account by receiving the funds of the
+// given send block.
+func (b *Builder) Open(ctx context.Context, source Hash, rep wallet.Address) (*OpenBlock, error) {
+	blk := &OpenBlock{
+		SourceHash:     source,
+		Representative: rep,
+		Address:        b.account.Address(),
+	}
+
+	var root Hash
+	copy(root[:], blk.Address)
+	if err := b.finish(ctx, blk, &blk.Common, root); err != nil {
+		return nil, err
+	}
+	return blk, nil
+}
+
+// Send creates a block that sends funds to the given destination. The balance
+// is what remains in the account after the send.
+func (b *Builder) Send(ctx context.Context, previous Hash, destination wallet.Address, balance wallet.Balance) (*SendBlock, error) {
+	blk := &SendBlock{
+		PreviousHash: previous,
+		Destination:  destination,
+		Balance:      balance,
+	}
+
+	if err := b.finish(ctx, blk, &blk.Common, blk.Root()); err != nil {
+		return nil, err
+	}
+	return blk, nil
+}
+
+// Receive creates a block that receives the funds of the given send block.
+func (b *Builder) Receive(ctx context.Context, previous Hash, source Hash) (*ReceiveBlock, error) {
+	blk := &ReceiveBlock{
+		PreviousHash: previous,
+		SourceHash:   source,
+	}
+
+	if err := b.finish(ctx, blk, &blk.Common, blk.Root()); err != nil {
+		return nil, err
+	}
+	return blk, nil
+}
+
+// Change creates a block that changes the representative of the account.
+func (b *Builder) Change(ctx context.Context, previous Hash, rep wallet.Address) (*ChangeBlock, error) {
+	blk := &ChangeBlock{
+		PreviousHash:   previous,
+		Representative: rep,
+	}
+
+	if err := b.finish(ctx, blk, &blk.Common, blk.Root()); err != nil {
+		return nil, err
+	}
+	return blk, nil
+}
+
+// State creates a state block for the account. The previous hash is zero if
+// the block opens the account.
+func (b *Builder) State(ctx context.Context, previous Hash, rep wallet.Address, balance wallet.Balance, link Hash) (*StateBlock, error) {
+	blk := &StateBlock{
+		Address:        b.account.Address(),
+		PreviousHash:   previous,
+		Representative: rep,
+		Balance:        balance,
+		Link:           link,
+	}
+
+	if err := b.finish(ctx, blk, &blk.Common, blk.Root()); err != nil {
+		return nil, err
+	}
+	return blk, nil
+}
+
+// finish signs the given block and attaches work for the given root if that is
+// enabled. Generating work is aborted with the error of the given context if
+// it's done before valid work is found.
+func (b *Builder) finish(ctx context.Context, blk Block, common *CommonBlock, root Hash) error {
+	hash := blk.Hash()
+	copy(common.Signature[:], b.account.Sign(hash[:]))
+
+	if b.Generator != nil {
+		if work, ok := b.account.Work(root); ok && Work(work).ValidThreshold(root, b.Threshold) {
+			common.Work = Work(work)
+		} else {
+			work, err := b.Generator.GenerateThreshold(ctx, root, b.Threshold)
+			if err != nil {
+				return err
+			}
+			common.Work = work
+		}
+	}
+
+	if b.Cache != nil {
+		b.Cache.Update(b.account, hash)
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package block
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/alexbakker/gonano/nano/wallet"
+)
+
+// testWorkThreshold is low enough to generate work for tests quickly.
+const testWorkThreshold = 0xff00000000000000
+
+func testAccount(t *testing.T, index uint32) *wallet.Account {
+	var seed wallet.Seed
+	copy(seed[:], "gonano test seed, do not use!!!!")
+
+	key, err := seed.Key(index)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return wallet.NewAccount(key)
+}
+
+func TestBuilder(t *testing.T) {
+	account := testAccount(t, 0)
+	builder := NewBuilder(account, nil)
+	address := account.Address()
+	ctx := context.Background()
+
+	var blocks []Block
+	add := func(blk Block, err error) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		blocks = append(blocks, blk)
+	}
+
+	add(builder.Open(ctx, sendBlock.Hash(), address))
+	add(builder.Send(ctx, openBlock.Hash(), address, wallet.ParseBalanceInts(0, 1)))
+	add(builder.Receive(ctx, sendBlock.Hash(), receiveBlock.Hash()))
+	add(builder.Change(ctx, receiveBlock.Hash(), address))
+	add(builder.State(ctx, changeBlock.Hash(), address, wallet.ParseBalanceInts(0, 1), Hash{}))
+
+	for _, blk := range blocks {
+		hash := blk.Hash()
+		signature := blk.Signature()
+		if !address.Verify(hash[:], signature[:]) {
+			t.Fatalf("bad signature for %s block", Name(blk.ID()))
+		}
+	}
+}
+
+func TestBuilderState(t *testing.T) {
+	account := testAccount(t, 2)
+	if !bytes.Equal(account.Address(), stateBlock.Address) {
+		t.Fatalf("unexpected address: %s", account.Address())
+	}
+
+	blk, err := NewBuilder(account, nil).State(context.Background(), stateBlock.PreviousHash, stateBlock.Representative, stateBlock.Balance, stateBlock.Link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if blk.Hash() != stateBlock.Hash() {
+		t.Fatalf("unexpected hash: %s", blk.Hash())
+	}
+	if blk.Signature() != stateBlock.Signature() {
+		t.Fatalf("unexpected signature: %s", blk.Signature())
+	}
+}
+
+func TestBuilderWork(t *testing.T) {
+	account := testAccount(t, 0)
+	builder := NewBuilder(account, NewWorkGenerator(1))
+	builder.Threshold = testWorkThreshold
+	blk, err := builder.Change(context.Background(), changeBlock.Hash(), account.Address())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !blk.Common.Work.ValidThreshold(blk.Root(), testWorkThreshold) {
+		t.Fatalf("work not valid")
+	}
+
+	// generating work should stop once the context is done
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err = builder.Send(ctx, blk.Hash(), account.Address(), wallet.ZeroBalance); err != context.Canceled {
+		t.Fatalf("expected the context to be canceled, got: %v", err)
+	}
+}
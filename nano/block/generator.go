@@ -0,0 +1,121 @@
+package block
+
+import (
+	"context"
+	"encoding/binary"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/alexbakker/gonano/nano/crypto/random"
+)
+
+const (
+	// workBatchSize is the amount of attempts a goroutine makes before checking
+	// whether it should stop.
+	workBatchSize = 1 << 12
+)
+
+// WorkGenerator generates proof of work by spreading the search over multiple
+// goroutines that each start at a random nonce.
+type WorkGenerator struct {
+	threads int
+
+	// Threshold is the minimum difficulty of the work that Generate searches
+	// for. It defaults to the threshold of the live network.
+	Threshold uint64
+
+	mutex    sync.Mutex
+	attempts uint64
+	duration time.Duration
+}
+
+// NewWorkGenerator creates a new work generator that uses the given amount of
+// goroutines. If threads is not positive, one goroutine per CPU is used.
+func NewWorkGenerator(threads int) *WorkGenerator {
+	if threads < 1 {
+		threads = runtime.NumCPU()
+	}
+
+	return &WorkGenerator{
+		threads:   threads,
+		Threshold: WorkThreshold,
+	}
+}
+
+// Generate searches for valid work for the given root according to the
+// threshold of the generator. It returns as soon as one of the goroutines finds
+// valid work, or with the error of the given context if it's done before that.
+func (g *WorkGenerator) Generate(ctx context.Context, root Hash) (Work, error) {
+	return g.GenerateThreshold(ctx, root, g.Threshold)
+}
+
+// GenerateThreshold is like Generate, but searches for work that meets the
+// given threshold.
+func (g *WorkGenerator) GenerateThreshold(ctx context.Context, root Hash, threshold uint64) (Work, error) {
+	nonces := make([]Work, g.threads)
+	for i := range nonces {
+		var nonce [workSize]byte
+		if err := random.Bytes(nonce[:]); err != nil {
+			return 0, err
+		}
+		nonces[i] = Work(binary.LittleEndian.Uint64(nonce[:]))
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	var attempts uint64
+	results := make(chan Work, g.threads)
+	start := time.Now()
+
+	for _, nonce := range nonces {
+		wg.Add(1)
+		go func(worker *Worker) {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				for i := 1; i <= workBatchSize; i++ {
+					if worker.Difficulty() >= threshold {
+						atomic.AddUint64(&attempts, uint64(i))
+						results <- worker.work
+						return
+					}
+					worker.work++
+				}
+				atomic.AddUint64(&attempts, workBatchSize)
+			}
+		}(NewWorker(nonce, root))
+	}
+
+	var work Work
+	var err error
+	select {
+	case work = <-results:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	cancel()
+	wg.Wait()
+
+	g.mutex.Lock()
+	g.attempts += atomic.LoadUint64(&attempts)
+	g.duration += time.Since(start)
+	g.mutex.Unlock()
+
+	return work, err
+}
+
+// Rate returns the average amount of attempts per second over all calls to
+// Generate so far.
+func (g *WorkGenerator) Rate() float64 {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	if g.duration == 0 {
+		return 0
+	}
+	return float64(g.attempts) / g.duration.Seconds()
+}
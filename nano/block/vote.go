@@ -8,6 +8,7 @@ import (
 	"github.com/alexbakker/gonano/nano/wallet"
 )
 
+// Vote represents the vote of a representative for a block.
 type Vote struct {
 	Address   wallet.Address
 	Signature Signature
@@ -15,6 +16,37 @@ type Vote struct {
 	Block     Block
 }
 
+// NewVote creates a vote for the given block with the given sequence number and
+// signs it with the given private key of a representative.
+func NewVote(key ed25519.PrivateKey, sequence uint64, blk Block) *Vote {
+	vote := &Vote{
+		Address:  wallet.Address(key.Public().(ed25519.PublicKey)),
+		Sequence: sequence,
+		Block:    blk,
+	}
+
+	hash := vote.Hash()
+	copy(vote.Signature[:], ed25519.Sign(key, hash[:]))
+	return vote
+}
+
+// Hash calculates the hash of this vote, which is the hash that is signed by the
+// representative. It's the hash of the block that is voted for, followed by the
+// sequence number encoded in little endian.
+func (v *Vote) Hash() Hash {
+	var sequenceBytes [8]byte
+	binary.LittleEndian.PutUint64(sequenceBytes[:], v.Sequence)
+
+	blockHash := v.Block.Hash()
+	return hashBytes(blockHash[:], sequenceBytes[:])
+}
+
+// Verify reports whether the signature of this vote is valid.
+func (v *Vote) Verify() bool {
+	hash := v.Hash()
+	return v.Address.Verify(hash[:], v.Signature[:])
+}
+
 // MarshalBinary implements the encoding.BinaryMarshaler interface.
 func (v *Vote) MarshalBinary() ([]byte, error) {
 	buf := new(bytes.Buffer)
@@ -0,0 +1,79 @@
+package block
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/alexbakker/gonano/nano/crypto/ed25519"
+	"github.com/alexbakker/gonano/nano/internal/util"
+)
+
+// These vectors follow vote::hash of the reference node: blake2b-256 over the
+// hash of the block, followed by the sequence number in little endian. No vote
+// captured from the live network is available, so they guard against
+// regressions only. The signature scheme itself is checked against the
+// signature of the genesis block of the live network (openBlock) in TestVote.
+var (
+	voteKeySeed   = util.MustDecodeHex("34f0a37aad20f4a260f0a5b3cb3d7fb50673212263e58a380bc10474bb039ce4")
+	voteAddress   = util.MustDecodeHex("b0311ea55708d6a53c75cdbf88300259c6d018522fe3d4d0a242e431f9e8b6d0")
+	voteSequence  = uint64(0x1337)
+	voteHash      = util.MustDecodeHex32("e034acbfca2505ea68e9294e844f8c06904773139ec10c70579d224972b20e2c")
+	voteSignature = util.MustDecodeHex64("5f16e145b585243617bffc0c8ff8bd2941dee66e6410a133d4ac2e2e6192b91da1af15c329d388ebf8befdfdc33c1742fc2622ea47a4bd9635b6a1c62abeab0a")
+)
+
+func TestVote(t *testing.T) {
+	// votes are signed the same way as blocks, so a signature made by the live
+	// network must be valid for this package as well
+	hash := openBlock.Hash()
+	signature := openBlock.Signature()
+	if !openBlock.Address.Verify(hash[:], signature[:]) {
+		t.Fatalf("signature of the live genesis block is not valid")
+	}
+
+	_, key, err := ed25519.GenerateKey(bytes.NewReader(voteKeySeed))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vote := NewVote(key, voteSequence, changeBlock)
+	if !bytes.Equal(vote.Address, voteAddress) {
+		t.Fatalf("unexpected address: %x", []byte(vote.Address))
+	}
+	if vote.Hash() != voteHash {
+		t.Fatalf("unexpected hash: %s", vote.Hash())
+	}
+	if vote.Signature != voteSignature {
+		t.Fatalf("unexpected signature: %s", vote.Signature)
+	}
+	if !vote.Verify() {
+		t.Fatalf("vote signature is not valid")
+	}
+
+	// changing the sequence number should invalidate the signature
+	vote.Sequence++
+	if vote.Verify() {
+		t.Fatalf("vote signature is valid after changing the sequence number")
+	}
+}
+
+func TestVoteMarshal(t *testing.T) {
+	_, key, err := ed25519.GenerateKey(bytes.NewReader(voteKeySeed))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vote := NewVote(key, voteSequence, stateBlock)
+	bytes, err := vote.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res := Vote{Block: new(StateBlock)}
+	if err = res.UnmarshalBinary(bytes); err != nil {
+		t.Fatal(err)
+	}
+
+	if res.Hash() != vote.Hash() || !res.Verify() {
+		t.Fatalf("votes not equal")
+	}
+}
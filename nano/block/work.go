@@ -5,12 +5,15 @@ import (
 	"encoding/hex"
 	"fmt"
 	"hash"
+	"math"
 
 	"golang.org/x/crypto/blake2b"
 )
 
 const (
-	workSize      = 8
+	workSize = 8
+
+	// WorkThreshold is the minimum difficulty of work on the live network.
 	WorkThreshold = 0xffffffc000000000
 )
 
@@ -22,8 +25,36 @@ type Worker struct {
 	hash hash.Hash
 }
 
+// Valid reports whether this is valid work for the given root according to the
+// threshold of the live network.
 func (w Work) Valid(root Hash) bool {
-	return NewWorker(w, root).Valid()
+	return w.ValidThreshold(root, WorkThreshold)
+}
+
+// ValidThreshold reports whether this is valid work for the given root
+// according to the given threshold.
+func (w Work) ValidThreshold(root Hash, threshold uint64) bool {
+	return w.Difficulty(root) >= threshold
+}
+
+// Difficulty calculates the difficulty value of this work for the given root.
+func (w Work) Difficulty(root Hash) uint64 {
+	return NewWorker(w, root).Difficulty()
+}
+
+// Multiplier returns how many times more difficult it is to find work with the
+// given difficulty than work that just meets the given base threshold.
+func Multiplier(difficulty uint64, base uint64) float64 {
+	return workDistance(base) / workDistance(difficulty)
+}
+
+// workDistance returns the distance between the given difficulty and the
+// maximum difficulty.
+func workDistance(difficulty uint64) float64 {
+	if difficulty == 0 {
+		return math.Exp2(64)
+	}
+	return float64(-difficulty)
 }
 
 // MarshalText implements the encoding.TextMarshaler interface.
@@ -67,7 +98,20 @@ func NewWorker(work Work, root Hash) *Worker {
 	}
 }
 
+// Valid reports whether the current work is valid according to the threshold of
+// the live network.
 func (w *Worker) Valid() bool {
+	return w.ValidThreshold(WorkThreshold)
+}
+
+// ValidThreshold reports whether the current work is valid according to the
+// given threshold.
+func (w *Worker) ValidThreshold(threshold uint64) bool {
+	return w.Difficulty() >= threshold
+}
+
+// Difficulty calculates the difficulty value of the current work.
+func (w *Worker) Difficulty() uint64 {
 	var workBytes [workSize]byte
 	binary.LittleEndian.PutUint64(workBytes[:], uint64(w.work))
 
@@ -76,8 +120,7 @@ func (w *Worker) Valid() bool {
 	w.hash.Write(w.root[:])
 
 	sum := w.hash.Sum(nil)
-	value := binary.LittleEndian.Uint64(sum)
-	return value >= WorkThreshold
+	return binary.LittleEndian.Uint64(sum)
 }
 
 func (w *Worker) Generate() Work {
@@ -1,8 +1,10 @@
 package block
 
 import (
+	"context"
 	"encoding/hex"
 	"testing"
+	"time"
 )
 
 func TestBlockWork(t *testing.T) {
@@ -30,3 +32,59 @@ func mustDecodeHash(t *testing.T, s string) Hash {
 	copy(hash[:], bytes)
 	return hash
 }
+
+func TestBlockWorkGeneratorCancel(t *testing.T) {
+	gen := NewWorkGenerator(2)
+	root := mustDecodeHash(t, "6529C605D4016F486B60861C49DDAD128D77642E748B3FE13BE411F00BA0918B")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := gen.Generate(ctx, root); err != context.Canceled {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if _, err := gen.Generate(ctx, root); err != context.DeadlineExceeded {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if gen.Rate() <= 0 {
+		t.Fatalf("no attempts were made")
+	}
+}
+
+func TestBlockWorkDifficulty(t *testing.T) {
+	work := Work(0xc2c306caf73b836f)
+	hash := mustDecodeHash(t, "6529C605D4016F486B60861C49DDAD128D77642E748B3FE13BE411F00BA0918B")
+
+	difficulty := work.Difficulty(hash)
+	if difficulty != 0xffffffddd8fc6753 {
+		t.Fatalf("unexpected difficulty: %x", difficulty)
+	}
+	if !work.ValidThreshold(hash, difficulty) || work.ValidThreshold(hash, difficulty+1) {
+		t.Fatalf("work not validated against the given threshold")
+	}
+
+	multiplier := Multiplier(difficulty, WorkThreshold)
+	if multiplier < 1.8739 || multiplier > 1.8740 {
+		t.Fatalf("unexpected multiplier: %f", multiplier)
+	}
+	if Multiplier(0xfffffff800000000, WorkThreshold) != 8 {
+		t.Fatalf("unexpected multiplier")
+	}
+}
+
+func TestBlockWorkGeneratorThreshold(t *testing.T) {
+	const threshold = 0xff00000000000000
+	gen := NewWorkGenerator(2)
+	root := mustDecodeHash(t, "6529C605D4016F486B60861C49DDAD128D77642E748B3FE13BE411F00BA0918B")
+
+	work, err := gen.GenerateThreshold(context.Background(), root, threshold)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !work.ValidThreshold(root, threshold) {
+		t.Fatalf("work not valid")
+	}
+}
@@ -0,0 +1,88 @@
+package block
+
+import (
+	"context"
+	"sync"
+
+	"github.com/alexbakker/gonano/nano/wallet"
+)
+
+// workCacheRequest is a request to precompute work for the head of an account.
+type workCacheRequest struct {
+	account *wallet.Account
+	root    Hash
+}
+
+// WorkCache precomputes work for the heads of wallet accounts in the background.
+// The work is stored in the account, where a Builder picks it up when it
+// creates the next block for that account.
+type WorkCache struct {
+	gen     *WorkGenerator
+	mutex   sync.Mutex
+	pending map[string]*workCacheRequest
+	signal  chan struct{}
+}
+
+// NewWorkCache creates a new work cache that uses the given generator.
+func NewWorkCache(gen *WorkGenerator) *WorkCache {
+	return &WorkCache{
+		gen:     gen,
+		pending: make(map[string]*workCacheRequest),
+		signal:  make(chan struct{}, 1),
+	}
+}
+
+// Update tells the cache about the new head of the given account. The head is
+// zero if the account hasn't been opened yet. If no work is cached for the new
+// head, it's queued for generation, replacing any request for an older head.
+func (c *WorkCache) Update(account *wallet.Account, head Hash) {
+	root := head
+	if root.IsZero() {
+		copy(root[:], account.Address())
+	}
+
+	if _, ok := account.Work(root); ok {
+		return
+	}
+
+	c.mutex.Lock()
+	c.pending[string(account.Address())] = &workCacheRequest{account: account, root: root}
+	c.mutex.Unlock()
+
+	select {
+	case c.signal <- struct{}{}:
+	default:
+	}
+}
+
+// Run generates work for the queued heads until the given context is done.
+func (c *WorkCache) Run(ctx context.Context) error {
+	for {
+		select {
+		case <-c.signal:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		for _, req := range c.take() {
+			work, err := c.gen.Generate(ctx, req.root)
+			if err != nil {
+				return err
+			}
+			req.account.SetWork(req.root, uint64(work))
+		}
+	}
+}
+
+// take removes all pending requests from the queue and returns them.
+func (c *WorkCache) take() []*workCacheRequest {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	reqs := make([]*workCacheRequest, 0, len(c.pending))
+	for key, req := range c.pending {
+		reqs = append(reqs, req)
+		delete(c.pending, key)
+	}
+	return reqs
+}
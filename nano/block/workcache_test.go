@@ -0,0 +1,64 @@
+package block
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestWorkCache(t *testing.T) {
+	account := testAccount(t, 0)
+	gen := NewWorkGenerator(1)
+	gen.Threshold = testWorkThreshold
+	cache := NewWorkCache(gen)
+
+	// make sure Run has returned before the test ends
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- cache.Run(ctx)
+	}()
+	defer func() {
+		cancel()
+		if err := <-done; err != context.Canceled {
+			t.Errorf("unexpected error from Run: %v", err)
+		}
+	}()
+
+	waitWork := func(root Hash) Work {
+		for i := 0; i < 100; i++ {
+			if work, ok := account.Work(root); ok {
+				return Work(work)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		t.Fatalf("no work was cached for %s", root)
+		return 0
+	}
+
+	// work for the open block of an account is generated for its address
+	var root Hash
+	copy(root[:], account.Address())
+	cache.Update(account, Hash{})
+	work := waitWork(root)
+	if !work.ValidThreshold(root, testWorkThreshold) {
+		t.Fatalf("cached work not valid")
+	}
+
+	// the builder should use the cached work and queue the new head
+	builder := NewBuilder(account, gen)
+	builder.Threshold = testWorkThreshold
+	builder.Cache = cache
+	blk, err := builder.State(ctx, Hash{}, account.Address(), stateBlock.Balance, stateBlock.Link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if blk.Common.Work != work {
+		t.Fatalf("cached work not used")
+	}
+
+	work = waitWork(blk.Hash())
+	if !work.ValidThreshold(blk.Hash(), testWorkThreshold) {
+		t.Fatalf("cached work not valid")
+	}
+}
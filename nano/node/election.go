@@ -0,0 +1,222 @@
+package node
+
+import (
+	"errors"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/alexbakker/gonano/nano/block"
+	"github.com/alexbakker/gonano/nano/store"
+)
+
+const (
+	// DefaultQuorum is the default percentage of the total supply that needs to
+	// vote for a block before it's confirmed.
+	DefaultQuorum = 50
+
+	electionExpiry = time.Minute * 5
+)
+
+var (
+	ErrBadVoteSignature = errors.New("bad vote signature")
+	ErrBadQuorum        = errors.New("quorum must be a percentage between 1 and 100")
+)
+
+type ElectionFunc func(blk block.Block)
+
+// Election keeps track of the votes for the competing blocks of a single root.
+type Election struct {
+	Root   block.Hash
+	blocks map[block.Hash]block.Block
+	votes  map[string]*block.Vote
+	start  time.Time
+}
+
+// Elections decides between competing blocks by tallying the votes of
+// representatives, weighted by the amount of funds that was delegated to them.
+type Elections struct {
+	mutex     sync.Mutex
+	ledger    *store.Ledger
+	quorum    int
+	elections map[block.Hash]*Election
+	cb        ElectionFunc
+	now       func() time.Time
+}
+
+// NewElections creates a new election subsystem. A block is confirmed once the
+// representatives that voted for it have more than the given percentage of the
+// total supply delegated to them. A quorum of zero defaults to DefaultQuorum.
+// The given function is called for every block that is confirmed.
+func NewElections(ledger *store.Ledger, quorum int, cb ElectionFunc) (*Elections, error) {
+	if quorum == 0 {
+		quorum = DefaultQuorum
+	}
+	if quorum < 1 || quorum > 100 {
+		return nil, ErrBadQuorum
+	}
+
+	return &Elections{
+		ledger:    ledger,
+		quorum:    quorum,
+		elections: make(map[block.Hash]*Election),
+		cb:        cb,
+		now:       time.Now,
+	}, nil
+}
+
+// Start opens an election between the given competing blocks. If an election
+// for the given root is already in progress, the blocks are added to it.
+func (e *Elections) Start(root block.Hash, blocks ...block.Block) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	e.purge()
+
+	election, ok := e.elections[root]
+	if !ok {
+		election = &Election{
+			Root:   root,
+			blocks: make(map[block.Hash]block.Block),
+			votes:  make(map[string]*block.Vote),
+			start:  e.now(),
+		}
+		e.elections[root] = election
+	}
+
+	for _, blk := range blocks {
+		election.blocks[blk.Hash()] = blk
+	}
+}
+
+// Len returns the amount of elections that are in progress.
+func (e *Elections) Len() int {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return len(e.elections)
+}
+
+// Vote adds the given vote to the election for the root of the block it votes
+// for. Votes for blocks that are not part of an election are ignored, as well
+// as votes with a sequence number that is not higher than the last vote of the
+// same representative. If a block reaches the quorum, the ledger is rolled over
+// to that block and the election is closed. If rolling over the ledger fails,
+// the election stays open, so that the next vote tries again.
+func (e *Elections) Vote(vote *block.Vote) error {
+	if !vote.Verify() {
+		return ErrBadVoteSignature
+	}
+
+	winner, err := e.vote(vote)
+	if err != nil || winner == nil {
+		return err
+	}
+
+	// the callback is called without holding the lock, so that it can use the
+	// elections itself
+	if e.cb != nil {
+		e.cb(winner)
+	}
+	return nil
+}
+
+// vote adds the given vote to its election and returns the winning block if
+// the vote decided the election.
+func (e *Elections) vote(vote *block.Vote) (block.Block, error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	election, ok := e.elections[electionRoot(vote.Block)]
+	if !ok {
+		return nil, nil
+	}
+
+	rep := string(vote.Address)
+	if last, ok := election.votes[rep]; ok && vote.Sequence <= last.Sequence {
+		return nil, nil
+	}
+	election.votes[rep] = vote
+
+	// the vote could be for a block we didn't know about yet
+	blockHash := vote.Block.Hash()
+	if _, ok := election.blocks[blockHash]; !ok {
+		election.blocks[blockHash] = vote.Block
+	}
+
+	winner, err := e.tally(election)
+	if err != nil || winner == nil {
+		return nil, err
+	}
+
+	if err := e.confirm(election, winner); err != nil {
+		return nil, err
+	}
+
+	delete(e.elections, election.Root)
+	return winner, nil
+}
+
+// tally sums up the voting weight per block of the given election and returns
+// the block that reached the quorum, if any.
+func (e *Elections) tally(election *Election) (block.Block, error) {
+	tally := make(map[block.Hash]*big.Int, len(election.blocks))
+	for _, vote := range election.votes {
+		weight, err := e.ledger.GetRepresentation(vote.Address)
+		if err != nil {
+			return nil, err
+		}
+
+		hash := vote.Block.Hash()
+		if _, ok := tally[hash]; !ok {
+			tally[hash] = big.NewInt(0)
+		}
+		tally[hash].Add(tally[hash], weight.BigInt())
+	}
+
+	// quorum is reached if weight * 100 > supply * quorum
+	threshold := new(big.Int).Mul(e.ledger.Supply().BigInt(), big.NewInt(int64(e.quorum)))
+	for hash, weight := range tally {
+		if new(big.Int).Mul(weight, big.NewInt(100)).Cmp(threshold) > 0 {
+			return election.blocks[hash], nil
+		}
+	}
+
+	return nil, nil
+}
+
+// confirm rolls back the competitors of the given winning block of the given
+// election and makes sure the winning block is in the ledger.
+func (e *Elections) confirm(election *Election, winner block.Block) error {
+	winnerHash := winner.Hash()
+
+	var losers []block.Hash
+	for hash := range election.blocks {
+		if !hash.Equal(winnerHash) {
+			losers = append(losers, hash)
+		}
+	}
+
+	return e.ledger.ResolveFork(election.Root, winner, losers)
+}
+
+// purge removes the elections that didn't reach the quorum in time.
+func (e *Elections) purge() {
+	for root, election := range e.elections {
+		if e.now().Sub(election.start) > electionExpiry {
+			delete(e.elections, root)
+		}
+	}
+}
+
+// electionRoot returns the root the given block competes for. This matches the
+// root the ledger uses to keep track of forks: the address of the account for
+// open blocks and the hash of the previous block for all other blocks.
+func electionRoot(blk block.Block) block.Hash {
+	if b, ok := blk.(*block.OpenBlock); ok {
+		var root block.Hash
+		copy(root[:], b.Address)
+		return root
+	}
+
+	return blk.Root()
+}
@@ -0,0 +1,302 @@
+package node
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/alexbakker/gonano/nano/block"
+	"github.com/alexbakker/gonano/nano/crypto/ed25519"
+	"github.com/alexbakker/gonano/nano/store"
+	"github.com/alexbakker/gonano/nano/wallet"
+)
+
+// The blocks in ../store/testdata/chains.json are signed with the keys of the
+// first accounts of this seed.
+const testSeed = "676f6e616e6f207465737420736565642c20646f206e6f742075736521212121"
+
+type testChains struct {
+	Genesis *block.OpenBlock
+	Blocks  []block.Block
+	Forks   []block.Block
+}
+
+func parseTestBlock(t *testing.T, data []byte) block.Block {
+	var values struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(data, &values); err != nil {
+		t.Fatal(err)
+	}
+
+	var blk block.Block
+	switch values.Type {
+	case "send":
+		blk = new(block.SendBlock)
+	case "receive":
+		blk = new(block.ReceiveBlock)
+	case "open":
+		blk = new(block.OpenBlock)
+	case "change":
+		blk = new(block.ChangeBlock)
+	case "state":
+		blk = new(block.StateBlock)
+	default:
+		t.Fatalf("unsupported block type: %s", values.Type)
+	}
+
+	if err := json.Unmarshal(data, blk); err != nil {
+		t.Fatal(err)
+	}
+	return blk
+}
+
+// initTestLedger initializes an in-memory ledger with the genesis block of
+// testdata/chains.json and adds the send from the genesis account to the second
+// account of the test seed, together with the open block of that account.
+func initTestLedger(t *testing.T) (*store.Ledger, *testChains) {
+	var file struct {
+		Genesis json.RawMessage   `json:"genesis"`
+		Blocks  []json.RawMessage `json:"blocks"`
+		Forks   []json.RawMessage `json:"forks"`
+	}
+
+	data, err := ioutil.ReadFile("../store/testdata/chains.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = json.Unmarshal(data, &file); err != nil {
+		t.Fatal(err)
+	}
+
+	chains := testChains{Genesis: parseTestBlock(t, file.Genesis).(*block.OpenBlock)}
+	for _, data := range file.Blocks {
+		chains.Blocks = append(chains.Blocks, parseTestBlock(t, data))
+	}
+	for _, data := range file.Forks {
+		chains.Forks = append(chains.Forks, parseTestBlock(t, data))
+	}
+
+	ledger, err := store.NewLedger(store.NewMemoryStore(), store.LedgerOptions{
+		GenesisBlock:   chains.Genesis,
+		GenesisBalance: wallet.ParseBalanceInts(0xffffffffffffffff, 0xffffffffffffffff),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, blk := range chains.Blocks[:2] {
+		if err = ledger.AddBlock(blk); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return ledger, &chains
+}
+
+func testKeys(t *testing.T, n int) []ed25519.PrivateKey {
+	seed, err := wallet.ParseSeed(testSeed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var keys []ed25519.PrivateKey
+	for i := 0; i < n; i++ {
+		key, err := seed.Key(uint32(i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+func hasBlock(t *testing.T, ledger *store.Ledger, hash block.Hash) bool {
+	_, err := ledger.GetBlock(hash)
+	if err != nil && err != store.ErrNotFound {
+		t.Fatal(err)
+	}
+	return err == nil
+}
+
+func TestElections(t *testing.T) {
+	ledger, chains := initTestLedger(t)
+	keys := testKeys(t, 2)
+
+	existing, fork := chains.Blocks[0], chains.Forks[0]
+	if err := ledger.AddBlock(fork); err == nil {
+		t.Fatalf("expected a fork")
+	}
+
+	var elections *Elections
+	var confirmed []block.Block
+	elections, err := NewElections(ledger, DefaultQuorum, func(blk block.Block) {
+		// the callback should be able to use the elections without deadlocking
+		if n := elections.Len(); n != 0 {
+			t.Errorf("expected the election to be closed, got %d elections", n)
+		}
+		confirmed = append(confirmed, blk)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	root := electionRoot(fork)
+	elections.Start(root, existing, fork)
+
+	vote := block.NewVote(keys[1], 1, fork)
+	vote.Sequence++
+	if err := elections.Vote(vote); err != ErrBadVoteSignature {
+		t.Fatalf("expected a bad signature error, got: %v", err)
+	}
+
+	votedFor := func(key ed25519.PrivateKey) block.Hash {
+		elections.mutex.Lock()
+		defer elections.mutex.Unlock()
+
+		vote, ok := elections.elections[root].votes[string(key.Public().(ed25519.PublicKey))]
+		if !ok {
+			t.Fatalf("no vote found")
+		}
+		return vote.Block.Hash()
+	}
+
+	// the second account only has the weight of the first send, which is not
+	// nearly enough to reach the quorum
+	if err := elections.Vote(block.NewVote(keys[1], 2, existing)); err != nil {
+		t.Fatal(err)
+	}
+	if elections.Len() != 1 || len(confirmed) != 0 {
+		t.Fatalf("election was decided without reaching the quorum")
+	}
+	if votedFor(keys[1]) != existing.Hash() {
+		t.Fatalf("vote was not counted")
+	}
+
+	// votes with an old sequence number are ignored
+	if err := elections.Vote(block.NewVote(keys[1], 1, fork)); err != nil {
+		t.Fatal(err)
+	}
+	if votedFor(keys[1]) != existing.Hash() {
+		t.Fatalf("vote with an old sequence number replaced a newer vote")
+	}
+
+	// votes with a newer sequence number replace the previous vote
+	if err := elections.Vote(block.NewVote(keys[1], 3, fork)); err != nil {
+		t.Fatal(err)
+	}
+	if votedFor(keys[1]) != fork.Hash() {
+		t.Fatalf("vote with a newer sequence number was not counted")
+	}
+
+	// a winning block that can't be added to the ledger leaves the election
+	// open, so that the next vote can decide it
+	bad := *fork.(*block.SendBlock)
+	bad.Balance = wallet.ZeroBalance
+	if err := elections.Vote(block.NewVote(keys[0], 1, &bad)); err == nil {
+		t.Fatalf("expected an error for a block with a bad signature")
+	}
+	if elections.Len() != 1 || len(confirmed) != 0 {
+		t.Fatalf("election was closed without confirming a block")
+	}
+	if !hasBlock(t, ledger, existing.Hash()) {
+		t.Fatalf("existing block was rolled back without confirming a block")
+	}
+
+	// the genesis account holds nearly all of the voting weight
+	if err := elections.Vote(block.NewVote(keys[0], 2, fork)); err != nil {
+		t.Fatal(err)
+	}
+	if len(confirmed) != 1 || confirmed[0].Hash() != fork.Hash() {
+		t.Fatalf("fork was not confirmed")
+	}
+	if elections.Len() != 0 {
+		t.Fatalf("election was not closed")
+	}
+
+	// the losing block was rolled back together with the open block that
+	// received it
+	if hasBlock(t, ledger, existing.Hash()) || hasBlock(t, ledger, chains.Blocks[1].Hash()) {
+		t.Fatalf("losing block was not rolled back")
+	}
+	if !hasBlock(t, ledger, fork.Hash()) {
+		t.Fatalf("winning block was not added to the ledger")
+	}
+	forks, err := ledger.GetForks(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(forks) != 0 {
+		t.Fatalf("fork list was not cleared")
+	}
+
+	// votes for closed elections are ignored
+	if err := elections.Vote(block.NewVote(keys[0], 3, existing)); err != nil {
+		t.Fatal(err)
+	}
+	if len(confirmed) != 1 {
+		t.Fatalf("closed election was decided again")
+	}
+}
+
+func TestElectionsExpiry(t *testing.T) {
+	ledger, chains := initTestLedger(t)
+	keys := testKeys(t, 1)
+
+	var confirmed []block.Block
+	elections, err := NewElections(ledger, DefaultQuorum, func(blk block.Block) {
+		confirmed = append(confirmed, blk)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	elections.now = func() time.Time {
+		return now
+	}
+
+	existing, fork := chains.Blocks[0], chains.Forks[0]
+	elections.Start(electionRoot(fork), existing, fork)
+
+	// expired elections are purged when the next election starts
+	now = now.Add(electionExpiry)
+	open := chains.Blocks[1]
+	elections.Start(electionRoot(open), open)
+	if elections.Len() != 2 {
+		t.Fatalf("election was purged before it expired")
+	}
+
+	now = now.Add(time.Second)
+	elections.Start(electionRoot(open), open)
+	if elections.Len() != 1 {
+		t.Fatalf("expired election was not purged")
+	}
+
+	// votes for expired elections are ignored
+	if err := elections.Vote(block.NewVote(keys[0], 1, fork)); err != nil {
+		t.Fatal(err)
+	}
+	if len(confirmed) != 0 || hasBlock(t, ledger, fork.Hash()) {
+		t.Fatalf("expired election was decided")
+	}
+}
+
+func TestElectionsQuorum(t *testing.T) {
+	ledger, _ := initTestLedger(t)
+
+	for _, quorum := range []int{-1, 101} {
+		if _, err := NewElections(ledger, quorum, nil); err != ErrBadQuorum {
+			t.Fatalf("expected a bad quorum error for %d, got: %v", quorum, err)
+		}
+	}
+
+	elections, err := NewElections(ledger, 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elections.quorum != DefaultQuorum {
+		t.Fatalf("expected the default quorum, got: %d", elections.quorum)
+	}
+}
@@ -0,0 +1,44 @@
+package node
+
+import (
+	"sync"
+
+	"github.com/alexbakker/gonano/nano/block"
+)
+
+// HashCache keeps track of a limited amount of recently seen hashes. If the
+// cache is full, the oldest hash is evicted to make room for a new one.
+type HashCache struct {
+	mutex  sync.Mutex
+	max    int
+	hashes map[block.Hash]struct{}
+	order  []block.Hash
+}
+
+// NewHashCache creates a new hash cache with the given maximum capacity.
+func NewHashCache(max int) *HashCache {
+	return &HashCache{
+		max:    max,
+		hashes: make(map[block.Hash]struct{}, max),
+	}
+}
+
+// Add adds the given hash to the cache. It reports whether the hash was not in
+// the cache yet.
+func (c *HashCache) Add(hash block.Hash) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if _, ok := c.hashes[hash]; ok {
+		return false
+	}
+
+	if len(c.order) >= c.max {
+		delete(c.hashes, c.order[0])
+		c.order = c.order[1:]
+	}
+
+	c.hashes[hash] = struct{}{}
+	c.order = append(c.order, hash)
+	return true
+}
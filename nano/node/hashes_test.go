@@ -0,0 +1,33 @@
+package node
+
+import (
+	"testing"
+
+	"github.com/alexbakker/gonano/nano/block"
+)
+
+func TestHashCache(t *testing.T) {
+	cache := NewHashCache(2)
+	hashes := []block.Hash{{1}, {2}, {3}}
+
+	if !cache.Add(hashes[0]) {
+		t.Fatalf("new hash reported as seen")
+	}
+	if cache.Add(hashes[0]) {
+		t.Fatalf("seen hash reported as new")
+	}
+
+	// the oldest hash is evicted once the cache is full
+	if !cache.Add(hashes[1]) || !cache.Add(hashes[2]) {
+		t.Fatalf("new hash reported as seen")
+	}
+	if cache.Add(hashes[1]) || cache.Add(hashes[2]) {
+		t.Fatalf("seen hash reported as new")
+	}
+	if !cache.Add(hashes[0]) {
+		t.Fatalf("evicted hash reported as seen")
+	}
+	if !cache.Add(hashes[1]) {
+		t.Fatalf("evicted hash reported as seen")
+	}
+}
@@ -1,16 +1,27 @@
 package node
 
 import (
+	"bufio"
+	"bytes"
 	"errors"
 	"fmt"
+	"io"
 	"net"
 	"time"
 
 	"github.com/alexbakker/gonano/nano/block"
+	"github.com/alexbakker/gonano/nano/crypto/ed25519"
 	"github.com/alexbakker/gonano/nano/node/proto"
 	"github.com/alexbakker/gonano/nano/store"
 )
 
+const (
+	// publishCacheSize is the amount of recently published block hashes that
+	// are remembered, so that blocks we've already processed are not flooded
+	// to our peers again.
+	publishCacheSize = 10000
+)
+
 var (
 	errBadIP        = errors.New("bad ip")
 	errIPv6Disabled = errors.New("tried to use ipv6 while it's disabled")
@@ -21,6 +32,7 @@ var (
 		EnableIPv6:   false,
 		EnableVoting: true,
 		MaxPeers:     15,
+		Quorum:       DefaultQuorum,
 	}
 )
 
@@ -30,6 +42,9 @@ type Node struct {
 	tcpConn *net.TCPListener
 	peers   *PeerList
 	ledger  *store.Ledger
+	seen    *HashCache
+
+	elections *Elections
 
 	frontiers []*block.Frontier
 }
@@ -40,9 +55,26 @@ type Options struct {
 	EnableVoting bool
 	MaxPeers     int
 	Peers        []*net.UDPAddr
+
+	// RepresentativeKeys are the private keys of the representatives this node
+	// votes for. Votes are only issued if EnableVoting is set as well.
+	RepresentativeKeys []ed25519.PrivateKey
+
+	// Quorum is the percentage of the total supply that needs to vote for a
+	// block before it wins an election. Defaults to DefaultQuorum.
+	Quorum int
+	// ConfirmFunc is called for every block that wins an election.
+	ConfirmFunc ElectionFunc
+	// WorkThreshold is the minimum difficulty of the work of blocks. Defaults
+	// to the threshold of the live network.
+	WorkThreshold uint64
 }
 
 func New(ledger *store.Ledger, options Options) (*Node, error) {
+	if options.WorkThreshold == 0 {
+		options.WorkThreshold = block.WorkThreshold
+	}
+
 	// setup the udp listener
 	udpAddr, err := net.ResolveUDPAddr("udp", options.Address)
 	if err != nil {
@@ -63,13 +95,22 @@ func New(ledger *store.Ledger, options Options) (*Node, error) {
 		return nil, err
 	}
 
-	return &Node{
+	node := &Node{
 		udpConn: udpConn,
 		tcpConn: tcpConn,
 		options: options,
 		peers:   NewPeerList(options.MaxPeers),
 		ledger:  ledger,
-	}, nil
+		seen:    NewHashCache(publishCacheSize),
+	}
+	node.elections, err = NewElections(ledger, options.Quorum, node.processConfirmedBlock)
+	if err != nil {
+		udpConn.Close()
+		tcpConn.Close()
+		return nil, err
+	}
+
+	return node, nil
 }
 
 func (n *Node) Run() error {
@@ -79,6 +120,7 @@ func (n *Node) Run() error {
 		}
 	}
 
+	go n.listenTCP()
 	go n.syncFontiers()
 	go n.syncBlocks()
 
@@ -121,12 +163,136 @@ func (n *Node) listenUDP() error {
 			continue
 		}
 	}
-
-	return nil
 }
 
 func (n *Node) listenTCP() error {
-	return errors.New("not implemented")
+	for {
+		conn, err := n.tcpConn.AcceptTCP()
+		if err != nil {
+			return err
+		}
+
+		go func() {
+			if err := n.handleConn(conn); err != nil {
+				fmt.Printf("error handling tcp connection: %s\n", err)
+			}
+		}()
+	}
+}
+
+// handleConn serves the requests that are sent over the given connection until
+// the peer closes it.
+func (n *Node) handleConn(conn *net.TCPConn) error {
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+	writer := bufio.NewWriter(&deadlineWriter{conn: conn, timeout: pushTimeout})
+
+	for {
+		head := make([]byte, proto.HeaderSize)
+		if err := conn.SetReadDeadline(time.Now().Add(pushTimeout)); err != nil {
+			return err
+		}
+		if _, err := io.ReadFull(reader, head); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+
+		var header proto.Header
+		if err := header.UnmarshalBinary(head); err != nil {
+			return err
+		}
+
+		size, err := header.PayloadSize()
+		if err != nil {
+			return err
+		}
+
+		data := make([]byte, proto.HeaderSize+size)
+		copy(data, head)
+		if _, err := io.ReadFull(reader, data[proto.HeaderSize:]); err != nil {
+			return err
+		}
+
+		packet, err := proto.Parse(data)
+		if err != nil {
+			return err
+		}
+
+		// bulk push is the only request that doesn't require a response
+		if p, ok := packet.(*proto.BulkPushPacket); ok {
+			if err := n.handleBulkPushPacket(conn, reader, p); err != nil {
+				return err
+			}
+			continue
+		}
+
+		pusher, err := n.newPusher(packet)
+		if err != nil {
+			return err
+		}
+
+		if err := pusher.Push(writer); err != nil {
+			return err
+		}
+		if err := writer.Flush(); err != nil {
+			return err
+		}
+	}
+}
+
+func (n *Node) newPusher(packet proto.Packet) (Pusher, error) {
+	switch p := packet.(type) {
+	case *proto.FrontierReqPacket:
+		return NewFrontierPusher(n.ledger, p), nil
+	case *proto.BulkPullPacket:
+		return NewBulkPullPusher(n.ledger, p), nil
+	case *proto.BulkPullBlocksPacket:
+		return NewBulkPullBlocksPusher(n.ledger, p), nil
+	default:
+		return nil, errBadProtocol
+	}
+}
+
+// handleBulkPushPacket reads the blocks that follow a bulk push packet and adds
+// them to the ledger.
+func (n *Node) handleBulkPushPacket(conn *net.TCPConn, reader io.Reader, packet *proto.BulkPushPacket) error {
+	var blocks []block.Block
+	for {
+		if err := conn.SetReadDeadline(time.Now().Add(pushTimeout)); err != nil {
+			return err
+		}
+
+		blk, err := readBlock(reader)
+		if err != nil {
+			if err == block.ErrNotABlock {
+				break
+			}
+			return err
+		}
+
+		// skip blocks with invalid work
+		if !blk.ValidThreshold(n.options.WorkThreshold) {
+			fmt.Printf("bad work for block: %s\n", blk.Hash())
+			continue
+		}
+
+		blocks = append(blocks, blk)
+		if len(blocks) >= syncCacheSize {
+			if err := n.ledger.AddBlocks(blocks); err != nil {
+				return err
+			}
+			blocks = nil
+		}
+	}
+
+	if len(blocks) > 0 {
+		return n.ledger.AddBlocks(blocks)
+	}
+
+	return nil
 }
 
 // syncFrontiers asks a random peer for a list of frontiers once every 5
@@ -147,15 +313,25 @@ func (n *Node) syncFontiers() error {
 
 		fmt.Printf("requesting frontiers from: %s\n", peer.Addr)
 
+		n.frontiers = nil
 		syncer := NewFrontierSyncer(n.processFrontier)
 		if err = Sync(syncer, peer); err == nil {
 			fmt.Printf("received frontiers: %d\n", len(n.frontiers))
-			syncer := NewBulkPullSyncer(n.processFrontierBlocks, n.frontiers)
+			syncer := NewBulkPullSyncer(n.processFrontierBlocks, n.frontiers, n.options.WorkThreshold)
 			if err := Sync(syncer, peer); err == nil {
 				if count, err := n.ledger.CountBlocks(); err == nil {
 					fmt.Printf("block count: %d\n", count)
 				}
 			}
+
+			// push the blocks the peer doesn't have
+			if err := n.pushMissingBlocks(peer); err != nil {
+				fmt.Printf("error pushing blocks: %s\n", err)
+			}
+		}
+
+		if err := n.ledger.PurgeUncheckedBlocks(); err != nil {
+			fmt.Printf("error purging unchecked blocks: %s\n", err)
 		}
 
 		// retry sooner if an error occurred
@@ -173,6 +349,61 @@ func (n *Node) syncFontiers() error {
 	return nil
 }
 
+// pushMissingBlocks compares our frontiers to the frontiers we received from
+// the given peer and pushes the blocks the peer is missing.
+func (n *Node) pushMissingBlocks(peer *Peer) error {
+	frontiers, err := n.ledger.GetFrontiers()
+	if err != nil {
+		return err
+	}
+
+	peerFrontiers := make(map[string]block.Hash, len(n.frontiers))
+	for _, frontier := range n.frontiers {
+		peerFrontiers[string(frontier.Address)] = frontier.Hash
+	}
+
+	var blocks []block.Block
+	for _, frontier := range frontiers {
+		peerHash, ok := peerFrontiers[string(frontier.Address)]
+		if ok && peerHash.Equal(frontier.Hash) {
+			continue
+		}
+
+		// if the peer knows about this account, only push the chain if the head
+		// block of the peer is part of our chain. otherwise, the peer is ahead
+		// of us or on a fork
+		if ok {
+			address, err := n.ledger.BlockAccount(peerHash)
+			if err != nil {
+				if err == store.ErrNotFound {
+					continue
+				}
+				return err
+			}
+			if !bytes.Equal(address, frontier.Address) {
+				continue
+			}
+		}
+
+		chain, err := n.ledger.GetChain(frontier.Address, peerHash)
+		if err != nil {
+			return err
+		}
+
+		// push the chain from the oldest to the newest block
+		for i := len(chain) - 1; i >= 0; i-- {
+			blocks = append(blocks, chain[i])
+		}
+	}
+
+	if len(blocks) == 0 {
+		return nil
+	}
+
+	fmt.Printf("pushing blocks to %s: %d\n", peer.Addr, len(blocks))
+	return Push(NewBulkPushPusher(blocks), peer)
+}
+
 func (n *Node) syncBlocks() error {
 	return nil
 }
@@ -183,8 +414,10 @@ func (n *Node) processFrontier(frontier *block.Frontier) {
 }
 
 func (n *Node) processFrontierBlocks(blocks []block.Block) {
-	// if we feed the list of blocks to the ledger in reverse, there's a good
-	// chance the blocks are magically in the right order
+	// the chain is sent from the newest to the oldest block, so we feed the list
+	// of blocks to the ledger in reverse. blocks that still end up out of order
+	// are kept in the unchecked list of the ledger until their dependencies
+	// arrive
 
 	// note: this modifies the original slice
 	for i, j := 0, len(blocks)-1; i < j; i, j = i+1, j-1 {
@@ -196,14 +429,35 @@ func (n *Node) processFrontierBlocks(blocks []block.Block) {
 	}
 }
 
-func (n *Node) processBlocks(blocks []block.Block) {
-	/*if err := n.ledger.AddBlocks(blocks); err != nil { fmt.Printf("error
-		processing blocks: %s\n", err)
-		return
-	}*/
-
+// processBlocks adds the given blocks to the ledger and returns the blocks that
+// were new to it. Blocks that are missing a dependency end up in the unchecked
+// list of the ledger and are not returned.
+func (n *Node) processBlocks(blocks []block.Block) []block.Block {
+	var added []block.Block
 	for _, blk := range blocks {
+		if err := n.ledger.AddBlock(blk); err != nil {
+			if forkErr, ok := err.(*store.ErrFork); ok {
+				if err := n.handleFork(forkErr); err != nil {
+					fmt.Printf("error handling fork: %s\n", err)
+				}
+			} else if err != store.ErrBlockExists {
+				fmt.Printf("error adding block %s: %s\n", blk.Hash(), err)
+			}
+			continue
+		}
+
 		fmt.Printf("block (%s) %s: %+v\n", block.Name(blk.ID()), blk.Hash(), blk)
+		added = append(added, blk)
+	}
+
+	return added
+}
+
+func (n *Node) processConfirmedBlock(blk block.Block) {
+	fmt.Printf("confirmed block (%s) %s\n", block.Name(blk.ID()), blk.Hash())
+
+	if n.options.ConfirmFunc != nil {
+		n.options.ConfirmFunc(blk)
 	}
 }
 
@@ -270,12 +524,58 @@ func (n *Node) handlePacket(addr *net.UDPAddr, packet proto.Packet) error {
 	switch p := packet.(type) {
 	case *proto.KeepAlivePacket:
 		return n.handleKeepAlivePacket(addr, p)
-	case *proto.ConfirmAckPacket:
-	case *proto.ConfirmReqPacket:
 	case *proto.PublishPacket:
+		return n.handlePublishPacket(addr, p)
+	case *proto.ConfirmReqPacket:
+		return n.handleConfirmReqPacket(addr, p)
+	case *proto.ConfirmAckPacket:
+		return n.handleConfirmAckPacket(addr, p)
 	default:
 		return errBadProtocol
 	}
+}
+
+// handlePublishPacket adds the block in the given packet to the ledger. If the
+// block is new to us, it is flooded to a random selection of our peers.
+func (n *Node) handlePublishPacket(addr *net.UDPAddr, packet *proto.PublishPacket) error {
+	blk := packet.Block
+
+	// drop blocks with invalid work before doing anything else with them
+	if !blk.ValidThreshold(n.options.WorkThreshold) {
+		return store.ErrBadWork
+	}
+
+	// don't process blocks we've recently seen again
+	if !n.seen.Add(blk.Hash()) {
+		return nil
+	}
+
+	if len(n.processBlocks([]block.Block{blk})) == 0 {
+		return nil
+	}
+
+	return n.floodBlock(addr, blk)
+}
+
+// floodBlock publishes the given block to a random selection of our peers,
+// except for the peer with the given address that sent it to us.
+func (n *Node) floodBlock(from *net.UDPAddr, blk block.Block) error {
+	peers, err := n.peers.Pick()
+	if err != nil {
+		return err
+	}
+
+	packet := &proto.PublishPacket{Type: blk.ID(), Block: blk}
+	for _, peer := range peers {
+		if from != nil && peer.Addr.IP.Equal(from.IP) && peer.Addr.Port == from.Port {
+			continue
+		}
+
+		if err := n.sendPacket(peer.Addr, packet); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
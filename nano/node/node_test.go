@@ -0,0 +1,168 @@
+package node
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/alexbakker/gonano/nano/block"
+	"github.com/alexbakker/gonano/nano/node/proto"
+)
+
+// listenTestPeer listens for packets on a random local port.
+func listenTestPeer(t *testing.T) *net.UDPConn {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return conn
+}
+
+// readTestPackets returns the packets that arrive on the given connection
+// before the given timeout expires.
+func readTestPackets(t *testing.T, conn *net.UDPConn, timeout time.Duration) []proto.Packet {
+	if err := conn.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+		t.Fatal(err)
+	}
+
+	var packets []proto.Packet
+	buf := make([]byte, 1024)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			if err, ok := err.(net.Error); ok && err.Timeout() {
+				return packets
+			}
+			t.Fatal(err)
+		}
+
+		packet, err := proto.Parse(buf[:n])
+		if err != nil {
+			t.Fatal(err)
+		}
+		packets = append(packets, packet)
+	}
+}
+
+func TestNodePublish(t *testing.T) {
+	ledger, chains := initTestLedger(t)
+
+	node, err := New(ledger, Options{Address: "127.0.0.1:0", MaxPeers: 2, Quorum: DefaultQuorum})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer node.Stop()
+
+	sender := listenTestPeer(t)
+	defer sender.Close()
+	receiver := listenTestPeer(t)
+	defer receiver.Close()
+
+	senderAddr := sender.LocalAddr().(*net.UDPAddr)
+	for _, conn := range []*net.UDPConn{sender, receiver} {
+		if _, err := node.peers.Add(conn.LocalAddr().(*net.UDPAddr)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	publish := func(blk block.Block) {
+		packet := &proto.PublishPacket{Type: blk.ID(), Block: blk}
+		if err := node.handlePublishPacket(senderAddr, packet); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// a new block is added to the ledger and flooded to every peer except for
+	// the one that sent it
+	blk := chains.Blocks[2]
+	publish(blk)
+	if !hasBlock(t, ledger, blk.Hash()) {
+		t.Fatalf("published block was not added to the ledger")
+	}
+
+	packets := readTestPackets(t, receiver, time.Second)
+	if len(packets) != 1 {
+		t.Fatalf("expected 1 packet, got: %d", len(packets))
+	}
+	packet, ok := packets[0].(*proto.PublishPacket)
+	if !ok || packet.Block.Hash() != blk.Hash() {
+		t.Fatalf("unexpected packet: %+v", packets[0])
+	}
+	if packets := readTestPackets(t, sender, 100*time.Millisecond); len(packets) != 0 {
+		t.Fatalf("block was flooded back to its sender")
+	}
+
+	// blocks that were seen recently or that are already in the ledger are not
+	// flooded again
+	publish(blk)
+	publish(chains.Blocks[0])
+	if packets := readTestPackets(t, receiver, 100*time.Millisecond); len(packets) != 0 {
+		t.Fatalf("expected no packets, got: %d", len(packets))
+	}
+}
+
+func TestNodeConfirmReq(t *testing.T) {
+	ledger, chains := initTestLedger(t)
+
+	// the second account has too little weight to decide elections on its own
+	keys := testKeys(t, 2)[1:]
+
+	node, err := New(ledger, Options{
+		Address:            "127.0.0.1:0",
+		EnableVoting:       true,
+		RepresentativeKeys: keys,
+		Quorum:             DefaultQuorum,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer node.Stop()
+
+	peer := listenTestPeer(t)
+	defer peer.Close()
+	addr := peer.LocalAddr().(*net.UDPAddr)
+
+	confirmReq := func(blk block.Block) []proto.Packet {
+		packet := &proto.ConfirmReqPacket{Type: blk.ID(), Block: blk}
+		if err := node.handleConfirmReqPacket(addr, packet); err != nil {
+			t.Fatal(err)
+		}
+		return readTestPackets(t, peer, 100*time.Millisecond)
+	}
+
+	checkVote := func(packets []proto.Packet, hash block.Hash, sequence uint64) {
+		if len(packets) != 1 {
+			t.Fatalf("expected 1 packet, got: %d", len(packets))
+		}
+		packet, ok := packets[0].(*proto.ConfirmAckPacket)
+		if !ok {
+			t.Fatalf("unexpected packet: %+v", packets[0])
+		}
+		vote := packet.Vote
+		if !vote.Verify() || vote.Block.Hash() != hash || vote.Sequence != sequence {
+			t.Fatalf("unexpected vote for %s with sequence %d", vote.Block.Hash(), vote.Sequence)
+		}
+	}
+
+	// blocks in the ledger are voted for
+	blk := chains.Blocks[0]
+	checkVote(confirmReq(blk), blk.Hash(), 1)
+
+	// blocks we don't know about are neither voted for nor added to the ledger
+	if packets := confirmReq(chains.Blocks[2]); len(packets) != 0 {
+		t.Fatalf("expected no packets, got: %d", len(packets))
+	}
+	if hasBlock(t, ledger, chains.Blocks[2].Hash()) {
+		t.Fatalf("requested block was added to the ledger")
+	}
+
+	// forks start an election, but our vote goes to the block in our ledger
+	fork := chains.Forks[0]
+	checkVote(confirmReq(fork), blk.Hash(), 2)
+	if hasBlock(t, ledger, fork.Hash()) {
+		t.Fatalf("fork was added to the ledger")
+	}
+	if node.elections.Len() != 1 {
+		t.Fatalf("expected 1 election, got: %d", node.elections.Len())
+	}
+}
@@ -10,6 +10,7 @@ import (
 var (
 	ErrMaxPeers   = errors.New("max amount of peers reached")
 	ErrPeerExists = errors.New("this peer already exists in the list")
+	ErrNoPeers    = errors.New("the peer list is empty")
 )
 
 // PeerList represents a list of peers.
@@ -99,6 +100,10 @@ func (l *PeerList) Pick() ([]*Peer, error) {
 
 // Random picks one random peer from the internal peer list and returns it.
 func (l *PeerList) Random() (*Peer, error) {
+	if len(l.peers) == 0 {
+		return nil, ErrNoPeers
+	}
+
 	i, err := random.Intn(len(l.peers))
 	if err != nil {
 		return nil, err
@@ -35,6 +35,14 @@ const (
 const (
 	HeaderSize = 8
 
+	frontierReqSize    = wallet.AddressSize + 8
+	bulkPullSize       = wallet.AddressSize + block.HashSize
+	bulkPullBlocksSize = block.HashSize*2 + 5
+
+	// blockTypeMask is the part of the header extensions that contains the type
+	// of the block in publish, confirm_req and confirm_ack packets.
+	blockTypeMask = 0x0f00
+
 	VersionMax   = 0x06
 	VersionUsing = 0x06
 	VersionMin   = 0x04
@@ -104,6 +112,8 @@ type BulkPullPacket struct {
 	Hash    block.Hash
 }
 
+type BulkPushPacket struct{}
+
 type BulkPullBlocksPacket struct {
 	Min   block.Hash
 	Max   block.Hash
@@ -162,7 +172,8 @@ func Parse(data []byte) (Packet, error) {
 		packet = &ConfirmAckPacket{Type: header.BlockType()}
 	case idPacketBulkPull:
 		packet = new(BulkPullPacket)
-	//case idPacketBulkPush:
+	case idPacketBulkPush:
+		packet = new(BulkPushPacket)
 	case idPacketFrontierReq:
 		packet = new(FrontierReqPacket)
 	case idPacketBulkPullBlocks:
@@ -180,6 +191,17 @@ func Parse(data []byte) (Packet, error) {
 
 func MarshalPacket(packet Packet) ([]byte, error) {
 	header := NewHeader(packet.ID())
+
+	// the type of the block in the packet is encoded in the header extensions
+	switch p := packet.(type) {
+	case *PublishPacket:
+		header.SetBlockType(p.Block.ID())
+	case *ConfirmReqPacket:
+		header.SetBlockType(p.Block.ID())
+	case *ConfirmAckPacket:
+		header.SetBlockType(p.Vote.Block.ID())
+	}
+
 	headerBytes, err := header.MarshalBinary()
 	if err != nil {
 		return nil, err
@@ -245,8 +267,33 @@ func (s *Header) UnmarshalBinary(data []byte) error {
 	return util.AssertReaderEOF(reader)
 }
 
+// PayloadSize returns the size of the packet that follows this header. Only
+// packets that are sent over TCP have a fixed size.
+func (s *Header) PayloadSize() (int, error) {
+	switch s.MessageType {
+	case idPacketFrontierReq:
+		return frontierReqSize, nil
+	case idPacketBulkPull:
+		return bulkPullSize, nil
+	case idPacketBulkPush:
+		return 0, nil
+	case idPacketBulkPullBlocks:
+		return bulkPullBlocksSize, nil
+	default:
+		return 0, ErrBadType
+	}
+}
+
+// BlockType returns the type of the block that is contained in the packet that
+// follows this header.
 func (s *Header) BlockType() byte {
-	return byte((s.Extensions & 0x0f00) >> 8)
+	return byte((s.Extensions & blockTypeMask) >> 8)
+}
+
+// SetBlockType sets the type of the block that is contained in the packet that
+// follows this header.
+func (s *Header) SetBlockType(blockType byte) {
+	s.Extensions = (s.Extensions &^ blockTypeMask) | (uint16(blockType) << 8 & blockTypeMask)
 }
 
 // MarshalBinary implements the encoding.BinaryMarshaler interface.
@@ -485,6 +532,23 @@ func (s *BulkPullPacket) ID() byte {
 	return idPacketBulkPull
 }
 
+// MarshalBinary implements the encoding.BinaryMarshaler interface.
+func (s *BulkPushPacket) MarshalBinary() ([]byte, error) {
+	return []byte{}, nil
+}
+
+// UnmarshalBinary implements the encoding.BinaryUnmarshaler interface.
+func (s *BulkPushPacket) UnmarshalBinary(data []byte) error {
+	if len(data) != 0 {
+		return ErrBadLength
+	}
+	return nil
+}
+
+func (s *BulkPushPacket) ID() byte {
+	return idPacketBulkPush
+}
+
 // MarshalBinary implements the encoding.BinaryMarshaler interface.
 func (s *BulkPullBlocksPacket) MarshalBinary() ([]byte, error) {
 	buf := new(bytes.Buffer)
@@ -1,13 +1,255 @@
 package node
 
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"time"
+
+	"github.com/alexbakker/gonano/nano/block"
+	"github.com/alexbakker/gonano/nano/node/proto"
+	"github.com/alexbakker/gonano/nano/store"
+	"github.com/alexbakker/gonano/nano/wallet"
+)
+
+const (
+	pushTimeout = time.Second * 2
+)
+
+var (
+	errPushDone = errors.New("push is done")
+)
+
 type Pusher interface {
+	// Push writes the data of this pusher to the given writer. For pushers that
+	// serve a request, this is the response to that request.
+	Push(w io.Writer) error
 }
 
 type FrontierPusher struct {
+	ledger *store.Ledger
+	packet *proto.FrontierReqPacket
 }
 
 type BulkPullPusher struct {
+	ledger *store.Ledger
+	packet *proto.BulkPullPacket
 }
 
 type BulkPullBlocksPusher struct {
+	ledger *store.Ledger
+	packet *proto.BulkPullBlocksPacket
+}
+
+type BulkPushPusher struct {
+	blocks []block.Block
+}
+
+// deadlineWriter resets the write deadline of the underlying connection before
+// every write.
+type deadlineWriter struct {
+	conn    *net.TCPConn
+	timeout time.Duration
+}
+
+func NewFrontierPusher(ledger *store.Ledger, packet *proto.FrontierReqPacket) *FrontierPusher {
+	return &FrontierPusher{ledger: ledger, packet: packet}
+}
+
+func NewBulkPullPusher(ledger *store.Ledger, packet *proto.BulkPullPacket) *BulkPullPusher {
+	return &BulkPullPusher{ledger: ledger, packet: packet}
+}
+
+func NewBulkPullBlocksPusher(ledger *store.Ledger, packet *proto.BulkPullBlocksPacket) *BulkPullBlocksPusher {
+	return &BulkPullBlocksPusher{ledger: ledger, packet: packet}
+}
+
+// NewBulkPushPusher creates a pusher that pushes the given blocks to a peer. The
+// blocks are sent in the given order, so they should be sorted from oldest to
+// newest.
+func NewBulkPushPusher(blocks []block.Block) *BulkPushPusher {
+	return &BulkPushPusher{blocks: blocks}
+}
+
+// Push sends the data of the given pusher to the given peer.
+func Push(pusher Pusher, peer *Peer) error {
+	conn, err := initSync(peer)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	writer := bufio.NewWriter(&deadlineWriter{conn: conn, timeout: pushTimeout})
+	if err := pusher.Push(writer); err != nil {
+		return err
+	}
+
+	return writer.Flush()
+}
+
+// Push implements the Pusher interface. The age field of the request is
+// ignored, as the ledger doesn't keep track of when accounts were modified.
+func (p *FrontierPusher) Push(w io.Writer) error {
+	var count uint32
+	err := p.ledger.WalkFrontiers(p.packet.StartAddress, func(frontier *block.Frontier) error {
+		if count >= p.packet.Count {
+			return errPushDone
+		}
+		count++
+
+		return writeFrontier(w, frontier)
+	})
+	if err != nil && err != errPushDone {
+		return err
+	}
+
+	// a zeroed frontier indicates the end of the transmission
+	return writeFrontier(w, &block.Frontier{
+		Address: make(wallet.Address, wallet.AddressSize),
+	})
+}
+
+// Push implements the Pusher interface.
+func (p *BulkPullPusher) Push(w io.Writer) error {
+	blocks, err := p.ledger.GetChain(p.packet.Address, p.packet.Hash)
+	if err != nil && err != store.ErrMissingAccount {
+		return err
+	}
+
+	for _, blk := range blocks {
+		if err := writeBlock(w, blk); err != nil {
+			return err
+		}
+	}
+
+	return writeNotABlock(w)
+}
+
+// Push implements the Pusher interface.
+func (p *BulkPullBlocksPusher) Push(w io.Writer) error {
+	var count uint32
+	var checksum block.Hash
+
+	err := p.ledger.WalkBlocks(p.packet.Min, func(blk block.Block) error {
+		if count >= p.packet.Count {
+			return errPushDone
+		}
+
+		hash := blk.Hash()
+		if bytes.Compare(hash[:], p.packet.Max[:]) > 0 {
+			return errPushDone
+		}
+		count++
+
+		switch p.packet.Mode {
+		case proto.BulkPullModeList:
+			return writeBlock(w, blk)
+		case proto.BulkPullModeChecksum:
+			for i := range checksum {
+				checksum[i] ^= hash[i]
+			}
+			return nil
+		default:
+			return errBadProtocol
+		}
+	})
+	if err != nil && err != errPushDone {
+		return err
+	}
+
+	if err := writeNotABlock(w); err != nil {
+		return err
+	}
+
+	// in checksum mode, the checksum is sent after the end of the transmission
+	if p.packet.Mode == proto.BulkPullModeChecksum {
+		_, err = w.Write(checksum[:])
+		return err
+	}
+
+	return nil
+}
+
+// Push implements the Pusher interface.
+func (p *BulkPushPusher) Push(w io.Writer) error {
+	packetBytes, err := proto.MarshalPacket(&proto.BulkPushPacket{})
+	if err != nil {
+		return err
+	}
+
+	if _, err = w.Write(packetBytes); err != nil {
+		return err
+	}
+
+	for _, blk := range p.blocks {
+		if err := writeBlock(w, blk); err != nil {
+			return err
+		}
+	}
+
+	return writeNotABlock(w)
+}
+
+func (w *deadlineWriter) Write(data []byte) (int, error) {
+	if err := w.conn.SetWriteDeadline(time.Now().Add(w.timeout)); err != nil {
+		return 0, err
+	}
+
+	return w.conn.Write(data)
+}
+
+func writeFrontier(w io.Writer, frontier *block.Frontier) error {
+	frontierBytes, err := frontier.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(frontierBytes)
+	return err
+}
+
+func writeBlock(w io.Writer, blk block.Block) error {
+	blockBytes, err := blk.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	if _, err = w.Write([]byte{blk.ID()}); err != nil {
+		return err
+	}
+
+	_, err = w.Write(blockBytes)
+	return err
+}
+
+func writeNotABlock(w io.Writer) error {
+	_, err := w.Write([]byte{block.IDNotABlock})
+	return err
+}
+
+// readBlock reads a block that is prefixed with its type from the given reader.
+// If the type is not_a_block, block.ErrNotABlock is returned.
+func readBlock(r io.Reader) (block.Block, error) {
+	var id [1]byte
+	if _, err := io.ReadFull(r, id[:]); err != nil {
+		return nil, err
+	}
+
+	blk, err := block.New(id[0])
+	if err != nil {
+		return nil, err
+	}
+
+	blockBytes := make([]byte, blk.Size())
+	if _, err := io.ReadFull(r, blockBytes); err != nil {
+		return nil, err
+	}
+
+	if err := blk.UnmarshalBinary(blockBytes); err != nil {
+		return nil, err
+	}
+
+	return blk, nil
 }
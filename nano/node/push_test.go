@@ -0,0 +1,275 @@
+package node
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/alexbakker/gonano/nano/block"
+	"github.com/alexbakker/gonano/nano/node/proto"
+	"github.com/alexbakker/gonano/nano/store"
+	"github.com/alexbakker/gonano/nano/wallet"
+)
+
+func readFrontiers(t *testing.T, r io.Reader) []*block.Frontier {
+	var frontiers []*block.Frontier
+	for {
+		buf := make([]byte, block.FrontierSize)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			t.Fatal(err)
+		}
+
+		var frontier block.Frontier
+		if err := frontier.UnmarshalBinary(buf); err != nil {
+			t.Fatal(err)
+		}
+		if frontier.IsZero() {
+			return frontiers
+		}
+		frontiers = append(frontiers, &frontier)
+	}
+}
+
+func readBlocks(t *testing.T, r io.Reader) []block.Block {
+	var blocks []block.Block
+	for {
+		blk, err := readBlock(r)
+		if err != nil {
+			if err == block.ErrNotABlock {
+				return blocks
+			}
+			t.Fatal(err)
+		}
+		blocks = append(blocks, blk)
+	}
+}
+
+func checkFrontiers(t *testing.T, frontiers []*block.Frontier, expected []*block.Frontier) {
+	if len(frontiers) != len(expected) {
+		t.Fatalf("expected %d frontiers, got: %d", len(expected), len(frontiers))
+	}
+	for i, frontier := range frontiers {
+		if frontier.Hash != expected[i].Hash || !bytes.Equal(frontier.Address, expected[i].Address) {
+			t.Fatalf("unexpected frontier %d: %s", i, frontier.Hash)
+		}
+	}
+}
+
+func checkBlocks(t *testing.T, blocks []block.Block, expected []block.Hash) {
+	if len(blocks) != len(expected) {
+		t.Fatalf("expected %d blocks, got: %d", len(expected), len(blocks))
+	}
+	for i, blk := range blocks {
+		if blk.Hash() != expected[i] {
+			t.Fatalf("unexpected block %d: %s", i, blk.Hash())
+		}
+	}
+}
+
+func TestFrontierPusher(t *testing.T) {
+	ledger, _ := initTestLedger(t)
+
+	frontiers, err := ledger.GetFrontiers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(frontiers) != 2 {
+		t.Fatalf("expected 2 frontiers, got: %d", len(frontiers))
+	}
+
+	push := func(start wallet.Address, count uint32) []*block.Frontier {
+		packet := &proto.FrontierReqPacket{
+			StartAddress: start,
+			Age:          math.MaxUint32,
+			Count:        count,
+		}
+
+		var buf bytes.Buffer
+		if err := NewFrontierPusher(ledger, packet).Push(&buf); err != nil {
+			t.Fatal(err)
+		}
+		return readFrontiers(t, &buf)
+	}
+
+	zero := make(wallet.Address, wallet.AddressSize)
+	checkFrontiers(t, push(zero, math.MaxUint32), frontiers)
+	checkFrontiers(t, push(zero, 1), frontiers[:1])
+	checkFrontiers(t, push(frontiers[1].Address, math.MaxUint32), frontiers[1:])
+	checkFrontiers(t, push(zero, 0), nil)
+}
+
+func TestBulkPullBlocksPusher(t *testing.T) {
+	ledger, chains := initTestLedger(t)
+	if err := ledger.AddBlocks(chains.Blocks[2:]); err != nil {
+		t.Fatal(err)
+	}
+
+	var hashes []block.Hash
+	err := ledger.WalkBlocks(block.Hash{}, func(blk block.Block) error {
+		hashes = append(hashes, blk.Hash())
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hashes) != len(chains.Blocks)+1 {
+		t.Fatalf("expected %d blocks, got: %d", len(chains.Blocks)+1, len(hashes))
+	}
+
+	push := func(mode proto.BulkPullMode, count uint32) *bytes.Buffer {
+		packet := &proto.BulkPullBlocksPacket{
+			Min:   hashes[2],
+			Max:   hashes[len(hashes)-2],
+			Mode:  mode,
+			Count: count,
+		}
+
+		var buf bytes.Buffer
+		if err := NewBulkPullBlocksPusher(ledger, packet).Push(&buf); err != nil {
+			t.Fatal(err)
+		}
+		return &buf
+	}
+
+	// only the blocks in the requested range are sent, in order of their hash
+	expected := hashes[2 : len(hashes)-1]
+	checkBlocks(t, readBlocks(t, push(proto.BulkPullModeList, math.MaxUint32)), expected)
+	checkBlocks(t, readBlocks(t, push(proto.BulkPullModeList, 2)), expected[:2])
+
+	// in checksum mode, the hashes of the blocks are xor'ed together
+	var checksum block.Hash
+	for _, hash := range expected {
+		for i := range checksum {
+			checksum[i] ^= hash[i]
+		}
+	}
+
+	buf := push(proto.BulkPullModeChecksum, math.MaxUint32)
+	checkBlocks(t, readBlocks(t, buf), nil)
+	if !bytes.Equal(buf.Bytes(), checksum[:]) {
+		t.Fatalf("unexpected checksum: %x", buf.Bytes())
+	}
+}
+
+func TestNodeTCP(t *testing.T) {
+	ledger, chains := initTestLedger(t)
+
+	node, err := New(ledger, Options{Address: "127.0.0.1:0", Quorum: DefaultQuorum})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer node.Stop()
+	go node.listenTCP()
+
+	addr := node.tcpConn.Addr().(*net.TCPAddr)
+	peer := &Peer{Addr: &net.UDPAddr{IP: addr.IP, Port: addr.Port}}
+
+	var frontiers []*block.Frontier
+	syncer := NewFrontierSyncer(func(frontier *block.Frontier) {
+		frontiers = append(frontiers, frontier)
+	})
+	if err := Sync(syncer, peer); err != nil {
+		t.Fatal(err)
+	}
+
+	expected, err := ledger.GetFrontiers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkFrontiers(t, frontiers, expected)
+
+	// the chains are pulled one account at a time, newest block first
+	var chainHashes [][]block.Hash
+	pullSyncer := NewBulkPullSyncer(func(blocks []block.Block) {
+		var hashes []block.Hash
+		for _, blk := range blocks {
+			hashes = append(hashes, blk.Hash())
+		}
+		chainHashes = append(chainHashes, hashes)
+	}, frontiers, block.WorkThreshold)
+	if err := Sync(pullSyncer, peer); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(chainHashes) != len(frontiers) {
+		t.Fatalf("expected %d chains, got: %d", len(frontiers), len(chainHashes))
+	}
+	for i, frontier := range frontiers {
+		var expected []block.Hash
+		if bytes.Equal(frontier.Address, chains.Genesis.Address) {
+			expected = []block.Hash{chains.Blocks[0].Hash(), chains.Genesis.Hash()}
+		} else {
+			expected = []block.Hash{chains.Blocks[1].Hash()}
+		}
+
+		if len(chainHashes[i]) != len(expected) {
+			t.Fatalf("expected %d blocks in chain %d, got: %d", len(expected), i, len(chainHashes[i]))
+		}
+		for j, hash := range expected {
+			if chainHashes[i][j] != hash {
+				t.Fatalf("unexpected block %d in chain %d: %s", j, i, chainHashes[i][j])
+			}
+		}
+	}
+}
+
+func TestNodeBulkPush(t *testing.T) {
+	ledger, chains := initTestLedger(t)
+	if err := ledger.AddBlocks(chains.Blocks[2:]); err != nil {
+		t.Fatal(err)
+	}
+	node, err := New(ledger, Options{Address: "127.0.0.1:0", Quorum: DefaultQuorum})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer node.Stop()
+
+	// the peer only has the first send of the genesis account and the open
+	// block that received it
+	peerLedger, _ := initTestLedger(t)
+	peerNode, err := New(peerLedger, Options{Address: "127.0.0.1:0", Quorum: DefaultQuorum})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer peerNode.Stop()
+	go peerNode.listenTCP()
+
+	addr := peerNode.tcpConn.Addr().(*net.TCPAddr)
+	peer := &Peer{Addr: &net.UDPAddr{IP: addr.IP, Port: addr.Port}}
+
+	count := func(ledger *store.Ledger) uint64 {
+		count, err := ledger.CountBlocks()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return count
+	}
+
+	// from the point of view of the peer, we are ahead of it, so it has nothing
+	// to push. connecting to the unused port would fail if it tried to
+	if peerNode.frontiers, err = ledger.GetFrontiers(); err != nil {
+		t.Fatal(err)
+	}
+	if err = peerNode.pushMissingBlocks(&Peer{Addr: &net.UDPAddr{IP: addr.IP, Port: 1}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if node.frontiers, err = peerLedger.GetFrontiers(); err != nil {
+		t.Fatal(err)
+	}
+	if err = node.pushMissingBlocks(peer); err != nil {
+		t.Fatal(err)
+	}
+
+	// the blocks are added by the peer after the push is complete
+	expected := count(ledger)
+	for i := 0; i < 100 && count(peerLedger) != expected; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := count(peerLedger); n != expected {
+		t.Fatalf("expected %d blocks after the push, got: %d", expected, n)
+	}
+}
@@ -51,27 +51,34 @@ type BulkPullSyncer struct {
 	current   block.Block
 	frontiers []*block.Frontier
 	i         int
+	pulling   bool
+	threshold uint64
 	cb        BulkPullSyncerFunc
 }
 
 type BulkPullBlocksSyncer struct {
-	blocks  []block.Block
-	current block.Block
-	sent    bool
-	mode    proto.BulkPullMode
-	cb      BulkPullBlocksSyncerFunc
+	blocks    []block.Block
+	current   block.Block
+	sent      bool
+	mode      proto.BulkPullMode
+	threshold uint64
+	cb        BulkPullBlocksSyncerFunc
 }
 
 func NewFrontierSyncer(cb FrontierSyncerFunc) *FrontierSyncer {
 	return &FrontierSyncer{cb: cb}
 }
 
-func NewBulkPullSyncer(cb BulkPullSyncerFunc, frontiers []*block.Frontier) *BulkPullSyncer {
-	return &BulkPullSyncer{cb: cb, frontiers: frontiers}
+// NewBulkPullSyncer returns a syncer that pulls the chains of the given
+// frontiers. Blocks with work below the given threshold are skipped.
+func NewBulkPullSyncer(cb BulkPullSyncerFunc, frontiers []*block.Frontier, threshold uint64) *BulkPullSyncer {
+	return &BulkPullSyncer{cb: cb, frontiers: frontiers, threshold: threshold}
 }
 
-func NewBulkPullBlocksSyncer(cb BulkPullBlocksSyncerFunc) *BulkPullBlocksSyncer {
-	return &BulkPullBlocksSyncer{cb: cb, mode: proto.BulkPullModeList}
+// NewBulkPullBlocksSyncer returns a syncer that pulls blocks by hash. Blocks
+// with work below the given threshold are skipped.
+func NewBulkPullBlocksSyncer(cb BulkPullBlocksSyncerFunc, threshold uint64) *BulkPullBlocksSyncer {
+	return &BulkPullBlocksSyncer{cb: cb, mode: proto.BulkPullModeList, threshold: threshold}
 }
 
 func Sync(syncer Syncer, peer *Peer) error {
@@ -106,7 +113,8 @@ func Sync(syncer Syncer, peer *Peer) error {
 			return err
 		}
 
-		var isDone bool
+		// a size of 0 indicates that the transmission is complete
+		isDone := size == 0
 		if size > 0 {
 			if err := conn.SetReadDeadline(time.Now().Add(syncTimeout)); err != nil {
 				return err
@@ -192,7 +200,7 @@ func (s *BulkPullSyncer) Parse(buf []byte) (bool, error) {
 
 	// skip blocks with invalid work
 	// todo: properly handle invalid blocks
-	if !s.current.Valid() {
+	if !s.current.ValidThreshold(s.threshold) {
 		fmt.Printf("bad work for block: %s\n", s.current.Hash())
 		return false, nil
 	}
@@ -217,6 +225,7 @@ func (s *BulkPullSyncer) Size(head []byte) (int, error) {
 		if err == block.ErrNotABlock {
 			// report this frontier block list to the caller
 			s.Flush()
+			s.pulling = false
 			return 0, nil
 		}
 		return 0, err
@@ -233,13 +242,15 @@ func (s *BulkPullSyncer) HeadSize() int {
 
 // NextPacket implements the Syncer interface.
 func (s *BulkPullSyncer) NextPacket() proto.Packet {
-	if s.i < len(s.frontiers) {
+	// only request the next chain once the current one has been received
+	if !s.pulling && s.i < len(s.frontiers) {
 		// request the chain of the next frontier
 		packet := &proto.BulkPullPacket{
 			Address: s.frontiers[s.i].Address,
 		}
 
 		s.i++
+		s.pulling = true
 		return packet
 	}
 
@@ -254,7 +265,7 @@ func (s *BulkPullBlocksSyncer) Parse(buf []byte) (bool, error) {
 
 	// skip blocks with invalid work
 	// todo: properly handle invalid blocks
-	if !s.current.Valid() {
+	if !s.current.ValidThreshold(s.threshold) {
 		fmt.Printf("bad work for block: %s\n", s.current.Hash())
 		return false, nil
 	}
@@ -0,0 +1,170 @@
+package node
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/alexbakker/gonano/nano/block"
+	"github.com/alexbakker/gonano/nano/crypto/ed25519"
+	"github.com/alexbakker/gonano/nano/node/proto"
+	"github.com/alexbakker/gonano/nano/store"
+	"github.com/alexbakker/gonano/nano/wallet"
+)
+
+// voting reports whether this node votes on blocks.
+func (n *Node) voting() bool {
+	return n.options.EnableVoting && len(n.options.RepresentativeKeys) > 0
+}
+
+// vote creates a vote for the given block for every configured representative.
+func (n *Node) vote(blk block.Block) ([]*block.Vote, error) {
+	var votes []*block.Vote
+	for _, key := range n.options.RepresentativeKeys {
+		address := wallet.Address(key.Public().(ed25519.PublicKey))
+		sequence, err := n.ledger.NextVoteSequence(address)
+		if err != nil {
+			return nil, err
+		}
+
+		votes = append(votes, block.NewVote(key, sequence, blk))
+	}
+
+	return votes, nil
+}
+
+// sendVotes sends the given votes to the given peers.
+func (n *Node) sendVotes(votes []*block.Vote, addrs []*net.UDPAddr) error {
+	for _, vote := range votes {
+		packet := &proto.ConfirmAckPacket{Type: vote.Block.ID(), Vote: *vote}
+		for _, addr := range addrs {
+			if err := n.sendPacket(addr, packet); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// handleConfirmReqPacket replies to the sender of the given packet with a vote
+// for the block in our ledger that has the same root as the requested block.
+// The requested block is never added to the ledger here. If it competes with
+// the block in our ledger, an election is started between the two.
+func (n *Node) handleConfirmReqPacket(addr *net.UDPAddr, packet *proto.ConfirmReqPacket) error {
+	if !n.voting() {
+		return nil
+	}
+
+	blk := packet.Block
+	if !blk.ValidThreshold(n.options.WorkThreshold) {
+		return store.ErrBadWork
+	}
+
+	existing, err := n.ledger.GetRootBlock(blk)
+	if err != nil {
+		// there's nothing to vote on if we don't know the block or its root
+		if err == store.ErrNotFound {
+			return nil
+		}
+		return err
+	}
+
+	votes, err := n.vote(existing)
+	if err != nil {
+		return err
+	}
+
+	if existing.Hash() != blk.Hash() {
+		if err := n.startElection(existing, blk, votes); err != nil {
+			return err
+		}
+	}
+
+	return n.sendVotes(votes, []*net.UDPAddr{addr})
+}
+
+// startElection starts an election between the given block in our ledger and
+// the given block that competes with it, if the latter was signed by the owner
+// of the account. Our votes for the block in our ledger are added to it.
+func (n *Node) startElection(existing block.Block, fork block.Block, votes []*block.Vote) error {
+	address, err := n.ledger.BlockAccount(existing.Hash())
+	if err != nil {
+		return err
+	}
+
+	hash := fork.Hash()
+	signature := fork.Signature()
+	if !address.Verify(hash[:], signature[:]) {
+		return nil
+	}
+
+	n.elections.Start(electionRoot(existing), existing, fork)
+	for _, vote := range votes {
+		if err := n.elections.Vote(vote); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// handleConfirmAckPacket adds the vote in the given packet to the election it
+// belongs to.
+func (n *Node) handleConfirmAckPacket(addr *net.UDPAddr, packet *proto.ConfirmAckPacket) error {
+	if !packet.Vote.Block.ValidThreshold(n.options.WorkThreshold) {
+		return store.ErrBadWork
+	}
+
+	return n.elections.Vote(&packet.Vote)
+}
+
+// handleFork starts an election between the blocks that compete for the root
+// of the fork described by the given error. Our peers are asked to vote on the
+// block in our ledger and, if this node votes, our own votes for that block are
+// broadcast as well.
+func (n *Node) handleFork(fork *store.ErrFork) error {
+	fmt.Printf("fork detected: %s\n", fork)
+
+	forks, err := n.ledger.GetForks(fork.Root)
+	if err != nil {
+		return err
+	}
+	n.elections.Start(fork.Root, forks...)
+
+	blk, err := n.ledger.GetBlock(fork.Existing)
+	if err != nil {
+		return err
+	}
+
+	peers, err := n.peers.Pick()
+	if err != nil {
+		return err
+	}
+	addrs := make([]*net.UDPAddr, len(peers))
+	for i, peer := range peers {
+		addrs[i] = peer.Addr
+	}
+
+	packet := &proto.ConfirmReqPacket{Type: blk.ID(), Block: blk}
+	for _, addr := range addrs {
+		if err := n.sendPacket(addr, packet); err != nil {
+			return err
+		}
+	}
+
+	if !n.voting() {
+		return nil
+	}
+
+	votes, err := n.vote(blk)
+	if err != nil {
+		return err
+	}
+	for _, vote := range votes {
+		if err := n.elections.Vote(vote); err != nil {
+			return err
+		}
+	}
+
+	return n.sendVotes(votes, addrs)
+}
@@ -0,0 +1,252 @@
+package store
+
+import (
+	"bytes"
+	"errors"
+
+	"github.com/alexbakker/gonano/nano/block"
+	"github.com/alexbakker/gonano/nano/wallet"
+)
+
+var (
+	ErrNotInChain = errors.New("block is not part of the account chain")
+)
+
+// HistoryType describes the effect a block had on the balance of its account.
+type HistoryType byte
+
+const (
+	HistorySend HistoryType = iota
+	HistoryReceive
+	HistoryChange
+)
+
+// AccountInfo contains the current state of an account in the ledger.
+type AccountInfo struct {
+	Address        wallet.Address
+	HeadBlock      block.Hash
+	OpenBlock      block.Hash
+	RepBlock       block.Hash
+	Representative wallet.Address
+	Balance        wallet.Balance
+}
+
+// HistoryEntry describes one block in the chain of an account. For sends,
+// Account is the destination and for receives it is the source account. For
+// representative changes, Account is the new representative and Amount is
+// zero.
+type HistoryEntry struct {
+	Hash    block.Hash
+	Type    HistoryType
+	Account wallet.Address
+	Amount  wallet.Balance
+}
+
+func (t HistoryType) String() string {
+	switch t {
+	case HistorySend:
+		return "send"
+	case HistoryReceive:
+		return "receive"
+	case HistoryChange:
+		return "change"
+	default:
+		return "unknown"
+	}
+}
+
+// AccountInfo returns the current state of the account with the given address.
+// ErrMissingAccount is returned if the account hasn't been opened yet.
+func (l *Ledger) AccountInfo(address wallet.Address) (*AccountInfo, error) {
+	var res *AccountInfo
+
+	err := l.db.View(func(txn StoreTxn) error {
+		info, err := txn.GetAddress(address)
+		if err != nil {
+			if err == ErrNotFound {
+				return ErrMissingAccount
+			}
+			return err
+		}
+
+		rep, err := l.getRepresentative(txn, address)
+		if err != nil {
+			return err
+		}
+
+		res = &AccountInfo{
+			Address:        address,
+			HeadBlock:      info.HeadBlock,
+			OpenBlock:      info.OpenBlock,
+			RepBlock:       info.RepBlock,
+			Representative: rep,
+			Balance:        info.Balance,
+		}
+		return nil
+	})
+
+	return res, err
+}
+
+// Balance returns the balance of the account with the given address. Accounts
+// that haven't been opened yet have a zero balance.
+func (l *Ledger) Balance(address wallet.Address) (wallet.Balance, error) {
+	res := wallet.ZeroBalance
+
+	err := l.db.View(func(txn StoreTxn) error {
+		info, err := txn.GetAddress(address)
+		if err != nil {
+			if err == ErrNotFound {
+				return nil
+			}
+			return err
+		}
+		res = info.Balance
+		return nil
+	})
+
+	return res, err
+}
+
+// BlockAccount returns the address of the account the block with the given
+// hash belongs to.
+func (l *Ledger) BlockAccount(hash block.Hash) (wallet.Address, error) {
+	var res wallet.Address
+
+	err := l.db.View(func(txn StoreTxn) error {
+		address, err := l.blockAccount(txn, hash)
+		if err != nil {
+			return err
+		}
+		res = address
+		return nil
+	})
+
+	return res, err
+}
+
+// AccountHistory returns the history of the account with the given address,
+// newest block first. The history starts at the given block, or at the head
+// block if the given hash is zero, and contains at most count entries. A count
+// of zero returns the rest of the chain.
+func (l *Ledger) AccountHistory(address wallet.Address, from block.Hash, count int) ([]*HistoryEntry, error) {
+	var entries []*HistoryEntry
+
+	err := l.db.View(func(txn StoreTxn) error {
+		info, err := txn.GetAddress(address)
+		if err != nil {
+			if err == ErrNotFound {
+				return ErrMissingAccount
+			}
+			return err
+		}
+
+		hash := info.HeadBlock
+		if !from.IsZero() {
+			owner, err := l.blockAccount(txn, from)
+			if err != nil {
+				return err
+			}
+			if !bytes.Equal(owner, address) {
+				return ErrNotInChain
+			}
+			hash = from
+		}
+
+		for !hash.IsZero() && (count == 0 || len(entries) < count) {
+			blk, err := txn.GetBlock(hash)
+			if err != nil {
+				return err
+			}
+
+			entry, err := l.historyEntry(txn, blk)
+			if err != nil {
+				return err
+			}
+			entries = append(entries, entry)
+			hash = previous(blk)
+		}
+
+		return nil
+	})
+
+	return entries, err
+}
+
+// historyEntry determines the type, counterparty and amount of the given block.
+func (l *Ledger) historyEntry(txn StoreTxn, blk block.Block) (*HistoryEntry, error) {
+	hash := blk.Hash()
+	info, err := txn.GetBlockInfo(hash)
+	if err != nil {
+		return nil, err
+	}
+	entry := HistoryEntry{Hash: hash, Amount: info.Amount}
+
+	var source block.Hash
+	switch b := blk.(type) {
+	case *block.SendBlock:
+		entry.Type, entry.Account = HistorySend, b.Destination
+		return &entry, nil
+	case *block.ReceiveBlock:
+		source = b.SourceHash
+	case *block.OpenBlock:
+		// the genesis block has no source, its funds come from nowhere
+		if hash.Equal(l.opts.GenesisBlock.Hash()) {
+			entry.Type, entry.Account = HistoryReceive, b.Address
+			return &entry, nil
+		}
+		source = b.SourceHash
+	case *block.ChangeBlock:
+		entry.Type, entry.Account = HistoryChange, b.Representative
+		return &entry, nil
+	case *block.StateBlock:
+		if info.Amount.Equal(wallet.ZeroBalance) {
+			entry.Type, entry.Account = HistoryChange, b.Representative
+			return &entry, nil
+		}
+		if !b.IsOpen() {
+			prevBalance, err := l.balance(txn, b.PreviousHash)
+			if err != nil {
+				return nil, err
+			}
+			if b.Balance.Compare(prevBalance) == wallet.BalanceCompSmaller {
+				entry.Type, entry.Account = HistorySend, wallet.Address(b.Link[:])
+				return &entry, nil
+			}
+		}
+		source = b.Link
+	default:
+		return nil, errors.New("unsupported block type")
+	}
+
+	account, err := l.blockAccount(txn, source)
+	if err != nil {
+		return nil, err
+	}
+
+	entry.Type, entry.Account = HistoryReceive, account
+	return &entry, nil
+}
+
+// Pending returns the send blocks that are waiting to be received by the
+// account with the given address, in order of their hash. Blocks that sent
+// less than the given threshold are left out.
+func (l *Ledger) Pending(address wallet.Address, threshold wallet.Balance) ([]*PendingBlock, error) {
+	var res []*PendingBlock
+
+	err := l.db.View(func(txn StoreTxn) error {
+		blocks, err := txn.GetPendings(address)
+		if err != nil {
+			return err
+		}
+
+		for _, blk := range blocks {
+			if blk.Amount.Compare(threshold) != wallet.BalanceCompSmaller {
+				res = append(res, blk)
+			}
+		}
+		return nil
+	})
+
+	return res, err
+}
@@ -1,7 +1,11 @@
 package store
 
 import (
+	"bytes"
+	"encoding/binary"
 	"errors"
+	"os"
+	"time"
 
 	"github.com/alexbakker/gonano/nano/block"
 	"github.com/alexbakker/gonano/nano/wallet"
@@ -14,6 +18,12 @@ const (
 	idPrefixFrontier
 	idPrefixPending
 	idPrefixRepresentation
+	idPrefixUnchecked
+	idPrefixFork
+	idPrefixVoteSequence
+	idPrefixMeta
+	idPrefixBlockInfo
+	idPrefixUncheckedExpiry
 )
 
 // BadgerStore represents a Nano block lattice store backed by a badger database.
@@ -26,6 +36,7 @@ type BadgerStoreTxn struct {
 }
 
 // NewBadgerStore initializes/opens a badger database in the given directory.
+// Databases with an older schema version are migrated to the current one.
 func NewBadgerStore(dir string) (*BadgerStore, error) {
 	opts := badger.DefaultOptions
 	opts.Dir = dir
@@ -36,6 +47,38 @@ func NewBadgerStore(dir string) (*BadgerStore, error) {
 		return nil, err
 	}
 
+	// refuse to open databases of an unknown version and upgrade old ones
+	if err := migrateBadger(db, badgerMigrations, badgerMigrationLimit); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &BadgerStore{db: db}, nil
+}
+
+// OpenBadgerStore opens an existing badger database in the given directory.
+// Unlike NewBadgerStore, it doesn't create or migrate the database: empty
+// databases and databases with another schema version are refused.
+func OpenBadgerStore(dir string) (*BadgerStore, error) {
+	// badger would create the directory
+	if _, err := os.Stat(dir); err != nil {
+		return nil, err
+	}
+
+	opts := badger.DefaultOptions
+	opts.Dir = dir
+	opts.ValueDir = dir
+
+	db, err := badger.Open(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkBadgerVersion(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
 	return &BadgerStore{db: db}, nil
 }
 
@@ -111,6 +154,9 @@ func (t *BadgerStoreTxn) GetBlock(hash block.Hash) (block.Block, error) {
 
 	item, err := t.txn.Get(key[:])
 	if err != nil {
+		if err == badger.ErrKeyNotFound {
+			return nil, ErrNotFound
+		}
 		return nil, err
 	}
 
@@ -155,6 +201,38 @@ func (t *BadgerStoreTxn) HasBlock(hash block.Hash) (bool, error) {
 	return true, nil
 }
 
+// WalkBlocks calls the given function for every block in the database with a
+// hash that is not smaller than the given start hash, in order of their hash.
+// If fn returns an error, the walk is stopped and that error is returned.
+func (t *BadgerStoreTxn) WalkBlocks(start block.Hash, fn func(blk block.Block) error) error {
+	it := t.txn.NewIterator(badger.DefaultIteratorOptions)
+	defer it.Close()
+
+	prefix := []byte{idPrefixBlock}
+	for it.Seek(append(prefix, start[:]...)); it.ValidForPrefix(prefix); it.Next() {
+		item := it.Item()
+		blockBytes, err := item.Value()
+		if err != nil {
+			return err
+		}
+
+		blk, err := block.New(item.UserMeta())
+		if err != nil {
+			return err
+		}
+
+		if err := blk.UnmarshalBinary(blockBytes); err != nil {
+			return err
+		}
+
+		if err := fn(blk); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 // CountBlocks returns the total amount of blocks in the database.
 func (t *BadgerStoreTxn) CountBlocks() (uint64, error) {
 	var count uint64
@@ -172,6 +250,54 @@ func (t *BadgerStoreTxn) CountBlocks() (uint64, error) {
 	return count, nil
 }
 
+// SetBlockInfo stores the given details of the block with the given hash.
+func (t *BadgerStoreTxn) SetBlockInfo(hash block.Hash, info *BlockInfo) error {
+	infoBytes, err := info.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	var key [1 + block.HashSize]byte
+	key[0] = idPrefixBlockInfo
+	copy(key[1:], hash[:])
+
+	return t.txn.Set(key[:], infoBytes)
+}
+
+// GetBlockInfo retrieves the details of the block with the given hash.
+func (t *BadgerStoreTxn) GetBlockInfo(hash block.Hash) (*BlockInfo, error) {
+	var key [1 + block.HashSize]byte
+	key[0] = idPrefixBlockInfo
+	copy(key[1:], hash[:])
+
+	item, err := t.txn.Get(key[:])
+	if err != nil {
+		if err == badger.ErrKeyNotFound {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	infoBytes, err := item.Value()
+	if err != nil {
+		return nil, err
+	}
+
+	var info BlockInfo
+	if err := info.UnmarshalBinary(infoBytes); err != nil {
+		return nil, err
+	}
+
+	return &info, nil
+}
+
+func (t *BadgerStoreTxn) DeleteBlockInfo(hash block.Hash) error {
+	var key [1 + block.HashSize]byte
+	key[0] = idPrefixBlockInfo
+	copy(key[1:], hash[:])
+	return t.txn.Delete(key[:])
+}
+
 func (t *BadgerStoreTxn) AddAddress(address wallet.Address, info *AddressInfo) error {
 	infoBytes, err := info.MarshalBinary()
 	if err != nil {
@@ -186,7 +312,7 @@ func (t *BadgerStoreTxn) AddAddress(address wallet.Address, info *AddressInfo) e
 	if _, err := t.txn.Get(key[:]); err != nil && err != badger.ErrKeyNotFound {
 		return err
 	} else if err == nil {
-		return errors.New("address already exists")
+		return ErrAddressExists
 	}
 
 	return t.txn.Set(key[:], infoBytes)
@@ -199,6 +325,9 @@ func (t *BadgerStoreTxn) GetAddress(address wallet.Address) (*AddressInfo, error
 
 	item, err := t.txn.Get(key[:])
 	if err != nil {
+		if err == badger.ErrKeyNotFound {
+			return nil, ErrNotFound
+		}
 		return nil, err
 	}
 
@@ -235,6 +364,36 @@ func (t *BadgerStoreTxn) DeleteAddress(address wallet.Address) error {
 	return t.txn.Delete(key[:])
 }
 
+// WalkAddresses calls the given function for every account in the database
+// with an address that is not smaller than the given start address, in order
+// of their address. If fn returns an error, the walk is stopped and that error
+// is returned.
+func (t *BadgerStoreTxn) WalkAddresses(start wallet.Address, fn func(address wallet.Address, info *AddressInfo) error) error {
+	it := t.txn.NewIterator(badger.DefaultIteratorOptions)
+	defer it.Close()
+
+	prefix := []byte{idPrefixAddress}
+	for it.Seek(append(prefix, start...)); it.ValidForPrefix(prefix); it.Next() {
+		item := it.Item()
+		infoBytes, err := item.Value()
+		if err != nil {
+			return err
+		}
+
+		var info AddressInfo
+		if err := info.UnmarshalBinary(infoBytes); err != nil {
+			return err
+		}
+
+		address := wallet.Address(append([]byte(nil), item.Key()[1:]...))
+		if err := fn(address, &info); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func (t *BadgerStoreTxn) AddFrontier(frontier *block.Frontier) error {
 	var key [1 + block.HashSize]byte
 	key[0] = idPrefixFrontier
@@ -244,7 +403,7 @@ func (t *BadgerStoreTxn) AddFrontier(frontier *block.Frontier) error {
 	if _, err := t.txn.Get(key[:]); err != nil && err != badger.ErrKeyNotFound {
 		return err
 	} else if err == nil {
-		return errors.New("frontier already exists")
+		return ErrFrontierExists
 	}
 
 	return t.txn.Set(key[:], frontier.Address)
@@ -257,6 +416,9 @@ func (t *BadgerStoreTxn) GetFrontier(hash block.Hash) (*block.Frontier, error) {
 
 	item, err := t.txn.Get(key[:])
 	if err != nil {
+		if err == badger.ErrKeyNotFound {
+			return nil, ErrNotFound
+		}
 		return nil, err
 	}
 
@@ -283,7 +445,7 @@ func (t *BadgerStoreTxn) GetFrontiers() ([]*block.Frontier, error) {
 
 		var frontier block.Frontier
 		frontier.Address = address
-		copy(frontier.Hash[:], item.Key())
+		copy(frontier.Hash[:], item.Key()[1:])
 
 		frontiers = append(frontiers, &frontier)
 	}
@@ -329,7 +491,7 @@ func (t *BadgerStoreTxn) AddPending(destination wallet.Address, hash block.Hash,
 	if _, err := t.txn.Get(key[:]); err != nil && err != badger.ErrKeyNotFound {
 		return err
 	} else if err == nil {
-		return errors.New("pending transaction already exists")
+		return ErrPendingExists
 	}
 
 	return t.txn.Set(key[:], pendingBytes)
@@ -343,6 +505,9 @@ func (t *BadgerStoreTxn) GetPending(destination wallet.Address, hash block.Hash)
 
 	item, err := t.txn.Get(key[:])
 	if err != nil {
+		if err == badger.ErrKeyNotFound {
+			return nil, ErrNotFound
+		}
 		return nil, err
 	}
 
@@ -359,6 +524,36 @@ func (t *BadgerStoreTxn) GetPending(destination wallet.Address, hash block.Hash)
 	return &pending, nil
 }
 
+// GetPendings retrieves all pending transactions of the given destination, in
+// order of their send block hash.
+func (t *BadgerStoreTxn) GetPendings(destination wallet.Address) ([]*PendingBlock, error) {
+	var blocks []*PendingBlock
+	it := t.txn.NewIterator(badger.DefaultIteratorOptions)
+	defer it.Close()
+
+	var prefix [1 + wallet.AddressSize]byte
+	prefix[0] = idPrefixPending
+	copy(prefix[1:], destination)
+
+	for it.Seek(prefix[:]); it.ValidForPrefix(prefix[:]); it.Next() {
+		item := it.Item()
+		pendingBytes, err := item.Value()
+		if err != nil {
+			return nil, err
+		}
+
+		var blk PendingBlock
+		if err := blk.Pending.UnmarshalBinary(pendingBytes); err != nil {
+			return nil, err
+		}
+		copy(blk.Hash[:], item.Key()[1+wallet.AddressSize:])
+
+		blocks = append(blocks, &blk)
+	}
+
+	return blocks, nil
+}
+
 func (t *BadgerStoreTxn) DeletePending(destination wallet.Address, hash block.Hash) error {
 	var key [1 + PendingKeySize]byte
 	key[0] = idPrefixPending
@@ -372,12 +567,9 @@ func (t *BadgerStoreTxn) setRepresentation(address wallet.Address, amount wallet
 	key[0] = idPrefixRepresentation
 	copy(key[1:], address)
 
-	amountBytes, err := amount.MarshalBinary()
-	if err != nil {
-		return err
-	}
-
-	return t.txn.Set(key[:], amountBytes)
+	// weights are stored in big endian since schema version 1, see
+	// migrateBadgerUnversioned
+	return t.txn.Set(key[:], amount.Bytes(binary.BigEndian))
 }
 
 func (t *BadgerStoreTxn) AddRepresentation(address wallet.Address, amount wallet.Balance) error {
@@ -423,3 +615,286 @@ func (t *BadgerStoreTxn) GetRepresentation(address wallet.Address) (wallet.Balan
 
 	return amount, nil
 }
+
+func (t *BadgerStoreTxn) AddUncheckedBlock(parentHash block.Hash, blk *UncheckedBlock) error {
+	blockBytes, err := blk.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	hash := blk.Block.Hash()
+	var key [1 + UncheckedKeySize]byte
+	key[0] = idPrefixUnchecked
+	copy(key[1:], parentHash[:])
+	copy(key[1+block.HashSize:], hash[:])
+
+	// never overwrite implicitly
+	if _, err := t.txn.Get(key[:]); err != nil && err != badger.ErrKeyNotFound {
+		return err
+	} else if err == nil {
+		return ErrUncheckedExists
+	}
+
+	if err := t.txn.Set(key[:], blockBytes); err != nil {
+		return err
+	}
+	return t.txn.Set(badgerUncheckedExpiryKey(blk.Timestamp, parentHash, hash), nil)
+}
+
+// badgerUncheckedExpiryKey returns the key of the given unchecked block in the
+// index that sorts the unchecked blocks by their timestamp.
+func badgerUncheckedExpiryKey(timestamp time.Time, parentHash block.Hash, hash block.Hash) []byte {
+	var key [1 + 8 + UncheckedKeySize]byte
+	key[0] = idPrefixUncheckedExpiry
+	copy(key[1:], uncheckedTimestamp(timestamp))
+	copy(key[1+8:], parentHash[:])
+	copy(key[1+8+block.HashSize:], hash[:])
+	return key[:]
+}
+
+// GetUncheckedBlocks retrieves all unchecked blocks that are waiting for the
+// block with the given hash.
+func (t *BadgerStoreTxn) GetUncheckedBlocks(parentHash block.Hash) ([]*UncheckedBlock, error) {
+	var blocks []*UncheckedBlock
+	it := t.txn.NewIterator(badger.DefaultIteratorOptions)
+	defer it.Close()
+
+	var prefix [1 + block.HashSize]byte
+	prefix[0] = idPrefixUnchecked
+	copy(prefix[1:], parentHash[:])
+
+	for it.Seek(prefix[:]); it.ValidForPrefix(prefix[:]); it.Next() {
+		blockBytes, err := it.Item().Value()
+		if err != nil {
+			return nil, err
+		}
+
+		var blk UncheckedBlock
+		if err := blk.UnmarshalBinary(blockBytes); err != nil {
+			return nil, err
+		}
+
+		blocks = append(blocks, &blk)
+	}
+
+	return blocks, nil
+}
+
+func (t *BadgerStoreTxn) DeleteUncheckedBlock(parentHash block.Hash, hash block.Hash) error {
+	var key [1 + UncheckedKeySize]byte
+	key[0] = idPrefixUnchecked
+	copy(key[1:], parentHash[:])
+	copy(key[1+block.HashSize:], hash[:])
+
+	// the timestamp is needed to remove the block from the expiry index
+	item, err := t.txn.Get(key[:])
+	if err != nil {
+		if err == badger.ErrKeyNotFound {
+			return nil
+		}
+		return err
+	}
+	blockBytes, err := item.Value()
+	if err != nil {
+		return err
+	}
+	var blk UncheckedBlock
+	if err := blk.UnmarshalBinary(blockBytes); err != nil {
+		return err
+	}
+
+	if err := t.txn.Delete(badgerUncheckedExpiryKey(blk.Timestamp, parentHash, hash)); err != nil {
+		return err
+	}
+	return t.txn.Delete(key[:])
+}
+
+// WalkUncheckedBlocks calls the given function for every unchecked block in the
+// database. If fn returns an error, the walk is stopped and that error is
+// returned.
+func (t *BadgerStoreTxn) WalkUncheckedBlocks(fn func(parentHash block.Hash, blk *UncheckedBlock) error) error {
+	it := t.txn.NewIterator(badger.DefaultIteratorOptions)
+	defer it.Close()
+
+	prefix := []byte{idPrefixUnchecked}
+	for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+		item := it.Item()
+		blockBytes, err := item.Value()
+		if err != nil {
+			return err
+		}
+
+		var blk UncheckedBlock
+		if err := blk.UnmarshalBinary(blockBytes); err != nil {
+			return err
+		}
+
+		var parentHash block.Hash
+		copy(parentHash[:], item.Key()[1:])
+
+		if err := fn(parentHash, &blk); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// WalkExpiredUncheckedBlocks calls the given function for every unchecked block
+// in the database with a timestamp before the given time, starting with the
+// oldest one. If fn returns an error, the walk is stopped and that error is
+// returned.
+func (t *BadgerStoreTxn) WalkExpiredUncheckedBlocks(before time.Time, fn func(parentHash block.Hash, hash block.Hash) error) error {
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchValues = false
+
+	it := t.txn.NewIterator(opts)
+	defer it.Close()
+
+	end := append([]byte{idPrefixUncheckedExpiry}, uncheckedTimestamp(before)...)
+	prefix := []byte{idPrefixUncheckedExpiry}
+	for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+		key := it.Item().Key()
+		if bytes.Compare(key, end) >= 0 {
+			break
+		}
+
+		var parentHash, hash block.Hash
+		copy(parentHash[:], key[1+8:])
+		copy(hash[:], key[1+8+block.HashSize:])
+
+		if err := fn(parentHash, hash); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (t *BadgerStoreTxn) CountUncheckedBlocks() (uint64, error) {
+	var count uint64
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchValues = false
+
+	it := t.txn.NewIterator(opts)
+	defer it.Close()
+
+	prefix := []byte{idPrefixUnchecked}
+	for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+		count++
+	}
+
+	return count, nil
+}
+
+// AddFork adds the given block to the list of competing blocks for the given
+// root.
+func (t *BadgerStoreTxn) AddFork(root block.Hash, blk block.Block) error {
+	hash := blk.Hash()
+	blockBytes, err := blk.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	var key [1 + block.HashSize*2]byte
+	key[0] = idPrefixFork
+	copy(key[1:], root[:])
+	copy(key[1+block.HashSize:], hash[:])
+
+	// never overwrite implicitly
+	if _, err := t.txn.Get(key[:]); err != nil && err != badger.ErrKeyNotFound {
+		return err
+	} else if err == nil {
+		return ErrForkExists
+	}
+
+	return t.txn.SetWithMeta(key[:], blockBytes, blk.ID())
+}
+
+// GetForks retrieves the competing blocks for the given root.
+func (t *BadgerStoreTxn) GetForks(root block.Hash) ([]block.Block, error) {
+	var blocks []block.Block
+	it := t.txn.NewIterator(badger.DefaultIteratorOptions)
+	defer it.Close()
+
+	var prefix [1 + block.HashSize]byte
+	prefix[0] = idPrefixFork
+	copy(prefix[1:], root[:])
+
+	for it.Seek(prefix[:]); it.ValidForPrefix(prefix[:]); it.Next() {
+		item := it.Item()
+		blockBytes, err := item.Value()
+		if err != nil {
+			return nil, err
+		}
+
+		blk, err := block.New(item.UserMeta())
+		if err != nil {
+			return nil, err
+		}
+
+		if err := blk.UnmarshalBinary(blockBytes); err != nil {
+			return nil, err
+		}
+
+		blocks = append(blocks, blk)
+	}
+
+	return blocks, nil
+}
+
+// DeleteForks removes all competing blocks for the given root.
+func (t *BadgerStoreTxn) DeleteForks(root block.Hash) error {
+	blocks, err := t.GetForks(root)
+	if err != nil {
+		return err
+	}
+
+	for _, blk := range blocks {
+		hash := blk.Hash()
+		var key [1 + block.HashSize*2]byte
+		key[0] = idPrefixFork
+		copy(key[1:], root[:])
+		copy(key[1+block.HashSize:], hash[:])
+
+		if err := t.txn.Delete(key[:]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (t *BadgerStoreTxn) SetVoteSequence(address wallet.Address, sequence uint64) error {
+	var key [1 + wallet.AddressSize]byte
+	key[0] = idPrefixVoteSequence
+	copy(key[1:], address)
+
+	var sequenceBytes [8]byte
+	binary.LittleEndian.PutUint64(sequenceBytes[:], sequence)
+	return t.txn.Set(key[:], sequenceBytes[:])
+}
+
+func (t *BadgerStoreTxn) GetVoteSequence(address wallet.Address) (uint64, error) {
+	var key [1 + wallet.AddressSize]byte
+	key[0] = idPrefixVoteSequence
+	copy(key[1:], address)
+
+	item, err := t.txn.Get(key[:])
+	if err != nil {
+		if err == badger.ErrKeyNotFound {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	sequenceBytes, err := item.Value()
+	if err != nil {
+		return 0, err
+	}
+	if len(sequenceBytes) != 8 {
+		return 0, errors.New("bad vote sequence size")
+	}
+
+	return binary.LittleEndian.Uint64(sequenceBytes), nil
+}
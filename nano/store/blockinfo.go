@@ -0,0 +1,67 @@
+package store
+
+import (
+	"bytes"
+	"encoding/binary"
+
+	"github.com/alexbakker/gonano/nano/internal/util"
+	"github.com/alexbakker/gonano/nano/wallet"
+)
+
+// BlockInfo contains the details of a block that can't be read from the block
+// itself without walking the account chain. Balance is the balance of the
+// account as of the block and Amount is the amount that was sent or received
+// by it, which is zero for representative changes.
+type BlockInfo struct {
+	Address wallet.Address
+	Balance wallet.Balance
+	Amount  wallet.Balance
+}
+
+// MarshalBinary implements the encoding.BinaryMarshaler interface.
+func (i *BlockInfo) MarshalBinary() ([]byte, error) {
+	buf := new(bytes.Buffer)
+
+	var err error
+	if _, err = buf.Write(i.Address); err != nil {
+		return nil, err
+	}
+
+	if _, err = buf.Write(i.Balance.Bytes(binary.BigEndian)); err != nil {
+		return nil, err
+	}
+
+	if _, err = buf.Write(i.Amount.Bytes(binary.BigEndian)); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary implements the encoding.BinaryUnmarshaler interface.
+func (i *BlockInfo) UnmarshalBinary(data []byte) error {
+	reader := bytes.NewReader(data)
+
+	i.Address = make(wallet.Address, wallet.AddressSize)
+	if _, err := reader.Read(i.Address); err != nil {
+		return err
+	}
+
+	balance := make([]byte, wallet.BalanceSize)
+	if _, err := reader.Read(balance); err != nil {
+		return err
+	}
+	if err := i.Balance.UnmarshalBinary(balance); err != nil {
+		return err
+	}
+
+	amount := make([]byte, wallet.BalanceSize)
+	if _, err := reader.Read(amount); err != nil {
+		return err
+	}
+	if err := i.Amount.UnmarshalBinary(amount); err != nil {
+		return err
+	}
+
+	return util.AssertReaderEOF(reader)
+}
@@ -1,8 +1,13 @@
 package store
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/alexbakker/gonano/nano/block"
 	"github.com/alexbakker/gonano/nano/wallet"
@@ -13,34 +18,130 @@ var (
 	ErrBadGenesis      = errors.New("genesis block in store doesn't match the given block")
 	ErrMissingPrevious = errors.New("previous block does not exist")
 	ErrMissingSource   = errors.New("source block does not exist")
+	ErrMissingAccount  = errors.New("account does not exist")
+	ErrUnreceivable    = errors.New("source block is not receivable")
+	ErrUncheckedFull   = errors.New("unchecked block list is full")
+	ErrMissingLink     = errors.New("state block receives without a source block")
 )
 
+const (
+	DefaultMaxUncheckedBlocks = 100000
+	DefaultUncheckedExpiry    = time.Hour * 4
+
+	// uncheckedPurgeLimit is the maximum amount of expired blocks that are
+	// removed from the unchecked list in a single transaction.
+	uncheckedPurgeLimit = 1000
+
+	// voteSequenceReserve is the amount of vote sequence numbers that are
+	// reserved in the store at a time.
+	voteSequenceReserve = 1000
+)
+
+// ErrFork is returned when a block competes with a block that's already in the
+// ledger, meaning that both blocks have the same root.
+type ErrFork struct {
+	Root     block.Hash
+	Existing block.Hash
+	Fork     block.Hash
+}
+
 type Ledger struct {
 	opts LedgerOptions
 	db   Store
+
+	// unchecked keeps track of the amount of unchecked blocks in the store, so
+	// that we don't have to count them every time one is added. It's only
+	// updated after a transaction has been committed.
+	unchecked uint64
+
+	// voteSequences keeps track of the last vote sequence number that was
+	// handed out per representative and of the range that was reserved for it
+	// in the store.
+	voteMutex     sync.Mutex
+	voteSequences map[string]*voteSequence
+}
+
+// voteSequence is the last vote sequence number that was handed out for a
+// representative, together with the highest one that was reserved in the store.
+type voteSequence struct {
+	last     uint64
+	reserved uint64
 }
 
 type LedgerOptions struct {
 	GenesisBlock   *block.OpenBlock
 	GenesisBalance wallet.Balance
+
+	// MaxUncheckedBlocks is the maximum amount of blocks that are kept in the
+	// unchecked list. Defaults to DefaultMaxUncheckedBlocks.
+	MaxUncheckedBlocks uint64
+	// UncheckedExpiry is the amount of time after which blocks in the unchecked
+	// list are considered to be expired. Defaults to DefaultUncheckedExpiry.
+	UncheckedExpiry time.Duration
+	// Clock returns the current time. It's used to timestamp and expire
+	// unchecked blocks. Defaults to time.Now.
+	Clock func() time.Time
+	// WorkThreshold is the minimum difficulty of the work of blocks. Defaults
+	// to the threshold of the live network.
+	WorkThreshold uint64
+}
+
+// uncheckedCount keeps track of the amount of unchecked blocks as seen by a
+// transaction. The counter of the ledger is only updated with it once the
+// transaction has been committed.
+type uncheckedCount struct {
+	start uint64
+	count uint64
 }
 
 func NewLedger(store Store, opts LedgerOptions) (*Ledger, error) {
-	ledger := Ledger{opts: opts, db: store}
+	if opts.MaxUncheckedBlocks == 0 {
+		opts.MaxUncheckedBlocks = DefaultMaxUncheckedBlocks
+	}
+	if opts.UncheckedExpiry == 0 {
+		opts.UncheckedExpiry = DefaultUncheckedExpiry
+	}
+	if opts.Clock == nil {
+		opts.Clock = time.Now
+	}
+	if opts.WorkThreshold == 0 {
+		opts.WorkThreshold = block.WorkThreshold
+	}
+	ledger := Ledger{
+		opts:          opts,
+		db:            store,
+		voteSequences: make(map[string]*voteSequence),
+	}
 
 	// initialize the store with the genesis block if needed
 	if err := ledger.setGenesis(opts.GenesisBlock, opts.GenesisBalance); err != nil {
 		return nil, err
 	}
 
+	err := store.View(func(txn StoreTxn) error {
+		count, err := txn.CountUncheckedBlocks()
+		if err != nil {
+			return err
+		}
+		ledger.unchecked = count
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
 	return &ledger, nil
 }
 
+func (e *ErrFork) Error() string {
+	return fmt.Sprintf("block %s competes with block %s (root: %s)", e.Fork, e.Existing, e.Root)
+}
+
 func (l *Ledger) setGenesis(blk *block.OpenBlock, balance wallet.Balance) error {
 	hash := blk.Hash()
 
 	// make sure the work value is valid
-	if !blk.Valid() {
+	if !blk.ValidThreshold(l.opts.WorkThreshold) {
 		fmt.Printf("bad work for genesis block")
 	}
 
@@ -67,7 +168,12 @@ func (l *Ledger) setGenesis(blk *block.OpenBlock, balance wallet.Balance) error
 				return ErrBadGenesis
 			}
 		} else {
-			if err := txn.AddBlock(blk); err != nil {
+			blockInfo := BlockInfo{
+				Address: blk.Address,
+				Balance: balance,
+				Amount:  balance,
+			}
+			if err := storeBlock(txn, blk, &blockInfo); err != nil {
 				return err
 			}
 
@@ -81,6 +187,10 @@ func (l *Ledger) setGenesis(blk *block.OpenBlock, balance wallet.Balance) error
 				return err
 			}
 
+			if err := txn.AddRepresentation(blk.Representative, balance); err != nil {
+				return err
+			}
+
 			return txn.AddFrontier(&block.Frontier{
 				Address: blk.Address,
 				Hash:    hash,
@@ -100,16 +210,18 @@ func (l *Ledger) addOpenBlock(txn StoreTxn, blk *block.OpenBlock) error {
 		return errors.New("bad block signature")
 	}
 
-	// make sure this address doesn't already exist
-	_, err := txn.GetAddress(blk.Address)
-	if err == nil {
-		return errors.New("account already exists")
+	// if this address already exists, this block competes with the existing
+	// open block
+	if info, err := txn.GetAddress(blk.Address); err == nil {
+		var root block.Hash
+		copy(root[:], blk.Address)
+		return l.recordFork(txn, root, info.OpenBlock, blk)
 	}
 
 	// obtain the pending transaction info
 	pending, err := txn.GetPending(blk.Address, blk.SourceHash)
 	if err != nil {
-		return ErrMissingSource
+		return ErrUnreceivable
 	}
 
 	// add address info
@@ -143,7 +255,11 @@ func (l *Ledger) addOpenBlock(txn StoreTxn, blk *block.OpenBlock) error {
 	}
 
 	// finally, add the block
-	return txn.AddBlock(blk)
+	return storeBlock(txn, blk, &BlockInfo{
+		Address: blk.Address,
+		Balance: pending.Amount,
+		Amount:  pending.Amount,
+	})
 }
 
 func (l *Ledger) addSendBlock(txn StoreTxn, blk *block.SendBlock) error {
@@ -152,8 +268,8 @@ func (l *Ledger) addSendBlock(txn StoreTxn, blk *block.SendBlock) error {
 	// make sure the hash of the previous block is a frontier
 	frontier, err := txn.GetFrontier(blk.Root())
 	if err != nil {
-		// todo: this indicates a fork!
-		return err
+		// the previous block exists, but it's not the head of its account
+		return l.addFork(txn, blk)
 	}
 
 	// make sure the signature of this block is valid
@@ -178,9 +294,10 @@ func (l *Ledger) addSendBlock(txn StoreTxn, blk *block.SendBlock) error {
 	}
 
 	// add this to the pending transaction list
+	amount := info.Balance.Sub(blk.Balance)
 	pending := Pending{
 		Address: frontier.Address,
-		Amount:  info.Balance.Sub(blk.Balance),
+		Amount:  amount,
 	}
 	if err := txn.AddPending(blk.Destination, hash, &pending); err != nil {
 		return err
@@ -198,12 +315,12 @@ func (l *Ledger) addSendBlock(txn StoreTxn, blk *block.SendBlock) error {
 	if err != nil {
 		return err
 	}
-	if err := txn.SubRepresentation(rep, blk.Balance); err != nil {
+	if err := txn.SubRepresentation(rep, amount); err != nil {
 		return err
 	}
 
 	// update the frontier of this account
-	if err := txn.DeleteFrontier(hash); err != nil {
+	if err := txn.DeleteFrontier(frontier.Hash); err != nil {
 		return err
 	}
 	frontier = &block.Frontier{
@@ -215,7 +332,11 @@ func (l *Ledger) addSendBlock(txn StoreTxn, blk *block.SendBlock) error {
 	}
 
 	// finally, add the block to the store
-	return txn.AddBlock(blk)
+	return storeBlock(txn, blk, &BlockInfo{
+		Address: frontier.Address,
+		Balance: blk.Balance,
+		Amount:  amount,
+	})
 }
 
 func (l *Ledger) addReceiveBlock(txn StoreTxn, blk *block.ReceiveBlock) error {
@@ -224,8 +345,8 @@ func (l *Ledger) addReceiveBlock(txn StoreTxn, blk *block.ReceiveBlock) error {
 	// make sure the hash of the previous block is a frontier
 	frontier, err := txn.GetFrontier(blk.Root())
 	if err != nil {
-		// todo: this indicates a fork!
-		return err
+		// the previous block exists, but it's not the head of its account
+		return l.addFork(txn, blk)
 	}
 
 	// make sure the signature of this block is valid
@@ -243,10 +364,19 @@ func (l *Ledger) addReceiveBlock(txn StoreTxn, blk *block.ReceiveBlock) error {
 		return errors.New("unexpected head block for account")
 	}
 
+	// make sure the source block exists
+	found, err := txn.HasBlock(blk.SourceHash)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return ErrMissingSource
+	}
+
 	// obtain the pending transaction info
 	pending, err := txn.GetPending(frontier.Address, blk.SourceHash)
 	if err != nil {
-		return ErrMissingSource
+		return ErrUnreceivable
 	}
 
 	// update the address info
@@ -271,7 +401,7 @@ func (l *Ledger) addReceiveBlock(txn StoreTxn, blk *block.ReceiveBlock) error {
 	}
 
 	// update the frontier of this account
-	if err := txn.DeleteFrontier(hash); err != nil {
+	if err := txn.DeleteFrontier(frontier.Hash); err != nil {
 		return err
 	}
 	frontier = &block.Frontier{
@@ -283,7 +413,11 @@ func (l *Ledger) addReceiveBlock(txn StoreTxn, blk *block.ReceiveBlock) error {
 	}
 
 	// finally, add the block to the store
-	return txn.AddBlock(blk)
+	return storeBlock(txn, blk, &BlockInfo{
+		Address: frontier.Address,
+		Balance: info.Balance,
+		Amount:  pending.Amount,
+	})
 }
 
 func (l *Ledger) addChangeBlock(txn StoreTxn, blk *block.ChangeBlock) error {
@@ -292,8 +426,8 @@ func (l *Ledger) addChangeBlock(txn StoreTxn, blk *block.ChangeBlock) error {
 	// make sure the hash of the previous block is a frontier
 	frontier, err := txn.GetFrontier(blk.Root())
 	if err != nil {
-		// todo: this indicates a fork!
-		return err
+		// the previous block exists, but it's not the head of its account
+		return l.addFork(txn, blk)
 	}
 
 	// make sure the signature of this block is valid
@@ -311,6 +445,12 @@ func (l *Ledger) addChangeBlock(txn StoreTxn, blk *block.ChangeBlock) error {
 		return errors.New("unexpected head block for account")
 	}
 
+	// obtain the old representative before the address info is updated
+	oldRep, err := l.getRepresentative(txn, frontier.Address)
+	if err != nil {
+		return err
+	}
+
 	// update the address info
 	info.HeadBlock = hash
 	info.RepBlock = hash
@@ -319,10 +459,6 @@ func (l *Ledger) addChangeBlock(txn StoreTxn, blk *block.ChangeBlock) error {
 	}
 
 	// update representative voting weight
-	oldRep, err := l.getRepresentative(txn, frontier.Address)
-	if err != nil {
-		return err
-	}
 	if err := txn.SubRepresentation(oldRep, info.Balance); err != nil {
 		return err
 	}
@@ -331,7 +467,7 @@ func (l *Ledger) addChangeBlock(txn StoreTxn, blk *block.ChangeBlock) error {
 	}
 
 	// update the frontier of this account
-	if err := txn.DeleteFrontier(hash); err != nil {
+	if err := txn.DeleteFrontier(frontier.Hash); err != nil {
 		return err
 	}
 	frontier = &block.Frontier{
@@ -343,14 +479,212 @@ func (l *Ledger) addChangeBlock(txn StoreTxn, blk *block.ChangeBlock) error {
 	}
 
 	// finally, add the block
-	return txn.AddBlock(blk)
+	return storeBlock(txn, blk, &BlockInfo{
+		Address: frontier.Address,
+		Balance: info.Balance,
+		Amount:  wallet.ZeroBalance,
+	})
+}
+
+// addStateBlock adds the given state block to the ledger. Whether the block
+// sends, receives or only changes the representative is derived from the
+// difference between its balance and the balance of the previous block.
+func (l *Ledger) addStateBlock(txn StoreTxn, blk *block.StateBlock) error {
+	hash := blk.Hash()
+
+	// make sure the signature of this block is valid
+	signature := blk.Signature()
+	if !blk.Address.Verify(hash[:], signature[:]) {
+		return errors.New("bad block signature")
+	}
+
+	var info *AddressInfo
+	var oldRep wallet.Address
+	oldBalance := wallet.ZeroBalance
+
+	if blk.IsOpen() {
+		// if this address already exists, this block competes with the existing
+		// open block
+		if info, err := txn.GetAddress(blk.Address); err == nil {
+			return l.recordFork(txn, blk.Root(), info.OpenBlock, blk)
+		}
+
+		info = &AddressInfo{OpenBlock: hash}
+	} else {
+		// make sure the hash of the previous block is a frontier
+		frontier, err := txn.GetFrontier(blk.PreviousHash)
+		if err != nil {
+			// the previous block exists, but it's not the head of its account
+			return l.addFork(txn, blk)
+		}
+		if !bytes.Equal(frontier.Address, blk.Address) {
+			return errors.New("previous block belongs to a different account")
+		}
+
+		// obtain account information and do some sanity checks
+		info, err = txn.GetAddress(blk.Address)
+		if err != nil {
+			return err
+		}
+		if !info.HeadBlock.Equal(frontier.Hash) {
+			return errors.New("unexpected head block for account")
+		}
+
+		oldRep, err = l.getRepresentative(txn, blk.Address)
+		if err != nil {
+			return err
+		}
+		oldBalance = info.Balance
+	}
+
+	amount := wallet.ZeroBalance
+	switch blk.Balance.Compare(oldBalance) {
+	case wallet.BalanceCompSmaller:
+		// this is a send, add it to the pending transaction list of the
+		// destination in the link field
+		amount = oldBalance.Sub(blk.Balance)
+		pending := Pending{
+			Address: blk.Address,
+			Amount:  amount,
+		}
+		if err := txn.AddPending(wallet.Address(blk.Link[:]), hash, &pending); err != nil {
+			return err
+		}
+	case wallet.BalanceCompBigger:
+		// this is a receive, make sure the source block in the link field exists
+		if blk.Link.IsZero() {
+			return ErrMissingLink
+		}
+		found, err := txn.HasBlock(blk.Link)
+		if err != nil {
+			return err
+		}
+		if !found {
+			return ErrMissingSource
+		}
+
+		// obtain the pending transaction info
+		pending, err := txn.GetPending(blk.Address, blk.Link)
+		if err != nil {
+			return ErrUnreceivable
+		}
+		amount = blk.Balance.Sub(oldBalance)
+		if !pending.Amount.Equal(amount) {
+			return fmt.Errorf("received amount doesn't match the pending amount: %s != %s", amount, pending.Amount)
+		}
+
+		// delete the pending transaction
+		if err := txn.DeletePending(blk.Address, blk.Link); err != nil {
+			return err
+		}
+	default:
+		// this is a representative change, which can't have a link
+		if blk.IsOpen() {
+			return errors.New("state block doesn't receive anything to open the account")
+		}
+		if !blk.Link.IsZero() {
+			return errors.New("unexpected link for a block that doesn't change the balance")
+		}
+	}
+
+	// add or update the address info
+	info.HeadBlock = hash
+	info.RepBlock = hash
+	info.Balance = blk.Balance
+	if blk.IsOpen() {
+		if err := txn.AddAddress(blk.Address, info); err != nil {
+			return err
+		}
+	} else {
+		if err := txn.UpdateAddress(blk.Address, info); err != nil {
+			return err
+		}
+	}
+
+	// update representative voting weight
+	if oldRep != nil {
+		if err := txn.SubRepresentation(oldRep, oldBalance); err != nil {
+			return err
+		}
+	}
+	if err := txn.AddRepresentation(blk.Representative, blk.Balance); err != nil {
+		return err
+	}
+
+	// update the frontier of this account
+	if !blk.IsOpen() {
+		if err := txn.DeleteFrontier(blk.PreviousHash); err != nil {
+			return err
+		}
+	}
+	frontier := block.Frontier{
+		Address: blk.Address,
+		Hash:    hash,
+	}
+	if err := txn.AddFrontier(&frontier); err != nil {
+		return err
+	}
+
+	// finally, add the block
+	return storeBlock(txn, blk, &BlockInfo{
+		Address: blk.Address,
+		Balance: blk.Balance,
+		Amount:  amount,
+	})
+}
+
+// addFork records the given block as a fork of the block that follows its
+// previous block in the account chain.
+func (l *Ledger) addFork(txn StoreTxn, blk block.Block) error {
+	hash := blk.Hash()
+	root := blk.Root()
+
+	address, err := l.blockAccount(txn, root)
+	if err != nil {
+		return err
+	}
+
+	// make sure the signature of this block is valid, so that we only keep track
+	// of forks that were created by the owner of the account
+	signature := blk.Signature()
+	if !address.Verify(hash[:], signature[:]) {
+		return errors.New("bad block signature")
+	}
+
+	existing, err := l.successor(txn, address, root)
+	if err != nil {
+		return err
+	}
+
+	return l.recordFork(txn, root, existing, blk)
+}
+
+// recordFork adds both the block with the given hash and the given competing
+// block to the fork list of the given root.
+func (l *Ledger) recordFork(txn StoreTxn, root block.Hash, existing block.Hash, blk block.Block) error {
+	existingBlk, err := txn.GetBlock(existing)
+	if err != nil {
+		return err
+	}
+
+	for _, b := range []block.Block{existingBlk, blk} {
+		if err := txn.AddFork(root, b); err != nil && err != ErrForkExists {
+			return err
+		}
+	}
+
+	return &ErrFork{
+		Root:     root,
+		Existing: existing,
+		Fork:     blk.Hash(),
+	}
 }
 
 func (l *Ledger) addBlock(txn StoreTxn, blk block.Block) error {
 	hash := blk.Hash()
 
 	// make sure the work value is valid
-	if !blk.Valid() {
+	if !blk.ValidThreshold(l.opts.WorkThreshold) {
 		return ErrBadWork
 	}
 
@@ -363,13 +697,16 @@ func (l *Ledger) addBlock(txn StoreTxn, blk block.Block) error {
 		return ErrBlockExists
 	}
 
-	// make sure the previous/source block exists
-	found, err = txn.HasBlock(blk.Root())
-	if err != nil {
-		return err
-	}
-	if !found {
-		return ErrMissingPrevious
+	// make sure the previous/source block exists, state blocks that open an
+	// account are checked for their source block later on
+	if b, ok := blk.(*block.StateBlock); !ok || !b.IsOpen() {
+		found, err = txn.HasBlock(blk.Root())
+		if err != nil {
+			return err
+		}
+		if !found {
+			return ErrMissingPrevious
+		}
 	}
 
 	switch b := blk.(type) {
@@ -381,29 +718,192 @@ func (l *Ledger) addBlock(txn StoreTxn, blk block.Block) error {
 		return l.addReceiveBlock(txn, b)
 	case *block.ChangeBlock:
 		return l.addChangeBlock(txn, b)
+	case *block.StateBlock:
+		return l.addStateBlock(txn, b)
 	default:
 		panic("bad block type")
 	}
 }
 
+// processBlock adds the given block to the ledger. If one of the dependencies
+// of the block is missing, it is added to the unchecked list instead. If the
+// block was added, the unchecked blocks that were waiting for it are processed
+// as well.
+func (l *Ledger) processBlock(txn StoreTxn, unchecked *uncheckedCount, blk block.Block) error {
+	if err := l.addBlock(txn, blk); err != nil {
+		if parentHash, ok := dependency(blk, err); ok {
+			if err := l.addUncheckedBlock(txn, unchecked, parentHash, blk); err != nil {
+				return err
+			}
+		}
+		return err
+	}
+
+	return l.processUncheckedBlocks(txn, unchecked, blk.Hash())
+}
+
+// processUncheckedBlocks adds the unchecked blocks that were waiting for the
+// block with the given hash to the ledger. This cascades to the unchecked
+// blocks that were waiting for those blocks.
+func (l *Ledger) processUncheckedBlocks(txn StoreTxn, unchecked *uncheckedCount, hash block.Hash) error {
+	queue := []block.Hash{hash}
+	for len(queue) > 0 {
+		parentHash := queue[0]
+		queue = queue[1:]
+
+		blocks, err := txn.GetUncheckedBlocks(parentHash)
+		if err != nil {
+			return err
+		}
+
+		for _, uncheckedBlock := range blocks {
+			blk := uncheckedBlock.Block
+			if err := txn.DeleteUncheckedBlock(parentHash, blk.Hash()); err != nil {
+				return err
+			}
+			if unchecked.count > 0 {
+				unchecked.count--
+			}
+
+			if err := l.addBlock(txn, blk); err != nil {
+				// the block may be waiting for yet another block
+				if parentHash, ok := dependency(blk, err); ok {
+					if err := l.addUncheckedBlock(txn, unchecked, parentHash, blk); err != nil && err != ErrUncheckedFull {
+						return err
+					}
+				}
+				continue
+			}
+
+			queue = append(queue, blk.Hash())
+		}
+	}
+
+	return nil
+}
+
+func (l *Ledger) addUncheckedBlock(txn StoreTxn, unchecked *uncheckedCount, parentHash block.Hash, blk block.Block) error {
+	// if the list is full, try to make some room by purging expired blocks
+	if unchecked.count >= l.opts.MaxUncheckedBlocks {
+		if _, err := l.purgeUncheckedBlocks(txn, unchecked); err != nil {
+			return err
+		}
+		if unchecked.count >= l.opts.MaxUncheckedBlocks {
+			return ErrUncheckedFull
+		}
+	}
+
+	uncheckedBlock := UncheckedBlock{
+		Block:     blk,
+		Timestamp: l.opts.Clock(),
+	}
+	if err := txn.AddUncheckedBlock(parentHash, &uncheckedBlock); err != nil {
+		if err == ErrUncheckedExists {
+			return nil
+		}
+		return err
+	}
+
+	unchecked.count++
+	return nil
+}
+
+// purgeUncheckedBlocks removes the oldest expired blocks from the unchecked
+// list, up to uncheckedPurgeLimit at a time. It returns the amount of blocks
+// that were removed.
+func (l *Ledger) purgeUncheckedBlocks(txn StoreTxn, unchecked *uncheckedCount) (int, error) {
+	type uncheckedKey struct {
+		parentHash block.Hash
+		hash       block.Hash
+	}
+
+	var expired []uncheckedKey
+	before := l.opts.Clock().Add(-l.opts.UncheckedExpiry)
+	err := txn.WalkExpiredUncheckedBlocks(before, func(parentHash block.Hash, hash block.Hash) error {
+		if len(expired) >= uncheckedPurgeLimit {
+			return errBatchDone
+		}
+		expired = append(expired, uncheckedKey{parentHash, hash})
+		return nil
+	})
+	if err != nil && err != errBatchDone {
+		return 0, err
+	}
+
+	for _, key := range expired {
+		if err := txn.DeleteUncheckedBlock(key.parentHash, key.hash); err != nil {
+			return 0, err
+		}
+		if unchecked.count > 0 {
+			unchecked.count--
+		}
+	}
+
+	return len(expired), nil
+}
+
+// update calls fn in an Update transaction of the store. The changes fn makes
+// to the amount of unchecked blocks are applied to the counter of the ledger
+// once the transaction has been committed.
+func (l *Ledger) update(fn func(txn StoreTxn, unchecked *uncheckedCount) error) error {
+	start := atomic.LoadUint64(&l.unchecked)
+	unchecked := uncheckedCount{start: start, count: start}
+
+	if err := l.db.Update(func(txn StoreTxn) error {
+		return fn(txn, &unchecked)
+	}); err != nil {
+		return err
+	}
+
+	atomic.AddUint64(&l.unchecked, unchecked.count-unchecked.start)
+	return nil
+}
+
+// AddBlock adds the given block to the ledger. If one of the dependencies of
+// the block is missing, the block is added to the unchecked list and
+// ErrMissingPrevious or ErrMissingSource is returned. If the block competes with
+// a block that's already in the ledger, both blocks are added to the fork list
+// and an *ErrFork is returned.
 func (l *Ledger) AddBlock(blk block.Block) error {
-	return l.db.Update(func(txn StoreTxn) error {
-		return l.addBlock(txn, blk)
+	var res error
+
+	err := l.update(func(txn StoreTxn, unchecked *uncheckedCount) error {
+		err := l.processBlock(txn, unchecked, blk)
+		if _, ok := dependency(blk, err); ok {
+			// commit the addition to the unchecked list
+			res = err
+			return nil
+		}
+		if _, ok := err.(*ErrFork); ok {
+			// commit the addition to the fork list
+			res = err
+			return nil
+		}
+		return err
 	})
+	if err != nil {
+		return err
+	}
+
+	return res
 }
 
 func (l *Ledger) AddBlocks(blocks []block.Block) error {
-	return l.db.Update(func(txn StoreTxn) error {
+	return l.update(func(txn StoreTxn, unchecked *uncheckedCount) error {
 		for _, blk := range blocks {
-			if err := l.addBlock(txn, blk); err != nil {
+			if err := l.processBlock(txn, unchecked, blk); err != nil {
 				switch err {
 				case ErrBlockExists:
 					// ignore
 				case ErrMissingPrevious:
 					fallthrough
 				case ErrMissingSource:
-					// add to unchecked list
+					// the block was added to the unchecked list
 				default:
+					if _, ok := err.(*ErrFork); ok {
+						// the block was added to the fork list
+						break
+					}
 					fmt.Printf("error adding block %s: %s\n", blk.Hash(), err)
 				}
 				continue
@@ -415,6 +915,196 @@ func (l *Ledger) AddBlocks(blocks []block.Block) error {
 	})
 }
 
+// GetForks returns the competing blocks for the given root. The root is the
+// hash of the previous block of the competing blocks, or the address of the
+// account for competing open blocks.
+func (l *Ledger) GetForks(root block.Hash) ([]block.Block, error) {
+	var res []block.Block
+
+	err := l.db.View(func(txn StoreTxn) error {
+		blocks, err := txn.GetForks(root)
+		if err != nil {
+			return err
+		}
+		res = blocks
+		return nil
+	})
+
+	return res, err
+}
+
+// GetRootBlock returns the block in the ledger that has the same root as the
+// given block. That's the given block itself if it's in the ledger, or the
+// block it competes with. ErrNotFound is returned if there is no such block.
+// Unlike AddBlock, this doesn't make any changes to the ledger.
+func (l *Ledger) GetRootBlock(blk block.Block) (block.Block, error) {
+	var res block.Block
+
+	err := l.db.View(func(txn StoreTxn) error {
+		var err error
+		if res, err = txn.GetBlock(blk.Hash()); err != ErrNotFound {
+			return err
+		}
+
+		var hash block.Hash
+		switch b := blk.(type) {
+		case *block.OpenBlock:
+			hash, err = l.openBlock(txn, b.Address)
+		case *block.StateBlock:
+			if b.IsOpen() {
+				hash, err = l.openBlock(txn, b.Address)
+				break
+			}
+			hash, err = l.rootSuccessor(txn, b.PreviousHash)
+		default:
+			hash, err = l.rootSuccessor(txn, blk.Root())
+		}
+		if err != nil {
+			return err
+		}
+
+		res, err = txn.GetBlock(hash)
+		return err
+	})
+
+	return res, err
+}
+
+// ResolveFork settles a fork in favor of the given winning block. The losing
+// blocks with the given hashes that are in the ledger are rolled back, the
+// winning block is added and the fork list of the given root is cleared in the
+// same transaction, so no other block can take the place of the winner in
+// between.
+func (l *Ledger) ResolveFork(root block.Hash, winner block.Block, losers []block.Hash) error {
+	return l.update(func(txn StoreTxn, unchecked *uncheckedCount) error {
+		for _, hash := range losers {
+			found, err := txn.HasBlock(hash)
+			if err != nil {
+				return err
+			}
+			if !found {
+				continue
+			}
+
+			if err := l.rollback(txn, hash); err != nil {
+				return err
+			}
+		}
+
+		if err := l.processBlock(txn, unchecked, winner); err != nil && err != ErrBlockExists {
+			return err
+		}
+
+		return txn.DeleteForks(root)
+	})
+}
+
+// GetBlock returns the block with the given hash.
+func (l *Ledger) GetBlock(hash block.Hash) (block.Block, error) {
+	var res block.Block
+
+	err := l.db.View(func(txn StoreTxn) error {
+		blk, err := txn.GetBlock(hash)
+		if err != nil {
+			return err
+		}
+		res = blk
+		return nil
+	})
+
+	return res, err
+}
+
+// GetRepresentation returns the voting weight of the representative with the
+// given address.
+func (l *Ledger) GetRepresentation(address wallet.Address) (wallet.Balance, error) {
+	res := wallet.ZeroBalance
+
+	err := l.db.View(func(txn StoreTxn) error {
+		weight, err := txn.GetRepresentation(address)
+		if err != nil {
+			return err
+		}
+		res = weight
+		return nil
+	})
+
+	return res, err
+}
+
+// Supply returns the total amount of funds in the ledger, which is the balance
+// of the genesis block.
+func (l *Ledger) Supply() wallet.Balance {
+	return l.opts.GenesisBalance
+}
+
+// NextVoteSequence increments the vote sequence number of the representative
+// with the given address and returns it. Sequence numbers are reserved in the
+// store in batches of voteSequenceReserve, so that votes issued after a restart
+// of the node don't reuse old numbers without writing every one of them.
+func (l *Ledger) NextVoteSequence(address wallet.Address) (uint64, error) {
+	l.voteMutex.Lock()
+	defer l.voteMutex.Unlock()
+
+	sequence, ok := l.voteSequences[string(address)]
+	if !ok || sequence.last >= sequence.reserved {
+		var reserved uint64
+		err := l.db.Update(func(txn StoreTxn) error {
+			stored, err := txn.GetVoteSequence(address)
+			if err != nil {
+				return err
+			}
+
+			reserved = stored + voteSequenceReserve
+			return txn.SetVoteSequence(address, reserved)
+		})
+		if err != nil {
+			return 0, err
+		}
+
+		// after a restart, continue after the numbers that may have been used
+		if !ok {
+			sequence = &voteSequence{last: reserved - voteSequenceReserve}
+			l.voteSequences[string(address)] = sequence
+		}
+		sequence.reserved = reserved
+	}
+
+	sequence.last++
+	return sequence.last, nil
+}
+
+// PurgeUncheckedBlocks removes all expired blocks from the unchecked list. The
+// blocks are removed in batches, each in a transaction of its own.
+func (l *Ledger) PurgeUncheckedBlocks() error {
+	for {
+		var n int
+		err := l.update(func(txn StoreTxn, unchecked *uncheckedCount) error {
+			var err error
+			n, err = l.purgeUncheckedBlocks(txn, unchecked)
+			return err
+		})
+		if err != nil || n < uncheckedPurgeLimit {
+			return err
+		}
+	}
+}
+
+func (l *Ledger) CountUncheckedBlocks() (uint64, error) {
+	var res uint64
+
+	err := l.db.View(func(txn StoreTxn) error {
+		count, err := txn.CountUncheckedBlocks()
+		if err != nil {
+			return err
+		}
+		res = count
+		return nil
+	})
+
+	return res, err
+}
+
 func (l *Ledger) CountBlocks() (uint64, error) {
 	var res uint64
 
@@ -430,6 +1120,82 @@ func (l *Ledger) CountBlocks() (uint64, error) {
 	return res, err
 }
 
+// GetFrontiers returns the frontiers of all accounts in the ledger, sorted by
+// account address.
+func (l *Ledger) GetFrontiers() ([]*block.Frontier, error) {
+	var res []*block.Frontier
+
+	err := l.db.View(func(txn StoreTxn) error {
+		frontiers, err := txn.GetFrontiers()
+		if err != nil {
+			return err
+		}
+		res = frontiers
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		return bytes.Compare(res[i].Address, res[j].Address) < 0
+	})
+
+	return res, nil
+}
+
+// GetChain returns the account chain of the given address, starting at the
+// head block. The chain ends at the open block of the account or right before
+// the given end block, whichever comes first. A zero end hash returns the full
+// chain.
+func (l *Ledger) GetChain(address wallet.Address, end block.Hash) ([]block.Block, error) {
+	var blocks []block.Block
+
+	err := l.db.View(func(txn StoreTxn) error {
+		info, err := txn.GetAddress(address)
+		if err != nil {
+			if err == ErrNotFound {
+				return ErrMissingAccount
+			}
+			return err
+		}
+
+		for hash := info.HeadBlock; !hash.IsZero() && !hash.Equal(end); {
+			blk, err := txn.GetBlock(hash)
+			if err != nil {
+				return err
+			}
+			blocks = append(blocks, blk)
+			hash = previous(blk)
+		}
+
+		return nil
+	})
+
+	return blocks, err
+}
+
+// WalkBlocks calls the given function for every block in the ledger with a
+// hash that is not smaller than the given start hash, in order of their hash.
+// If fn returns an error, the walk is stopped and that error is returned.
+func (l *Ledger) WalkBlocks(start block.Hash, fn func(blk block.Block) error) error {
+	return l.db.View(func(txn StoreTxn) error {
+		return txn.WalkBlocks(start, fn)
+	})
+}
+
+// WalkFrontiers calls the given function for the frontier of every account in
+// the ledger with an address that is not smaller than the given start address,
+// in order of their address. If fn returns an error, the walk is stopped and
+// that error is returned.
+func (l *Ledger) WalkFrontiers(start wallet.Address, fn func(frontier *block.Frontier) error) error {
+	return l.db.View(func(txn StoreTxn) error {
+		return txn.WalkAddresses(start, func(address wallet.Address, info *AddressInfo) error {
+			return fn(&block.Frontier{Address: address, Hash: info.HeadBlock})
+		})
+	})
+}
+
 func (l *Ledger) getRepresentative(txn StoreTxn, address wallet.Address) (wallet.Address, error) {
 	info, err := txn.GetAddress(address)
 	if err != nil {
@@ -441,12 +1207,140 @@ func (l *Ledger) getRepresentative(txn StoreTxn, address wallet.Address) (wallet
 		return nil, err
 	}
 
+	return blockRepresentative(blk)
+}
+
+// blockRepresentative returns the representative that is set by the given
+// block.
+func blockRepresentative(blk block.Block) (wallet.Address, error) {
 	switch b := blk.(type) {
 	case *block.OpenBlock:
 		return b.Representative, nil
 	case *block.ChangeBlock:
 		return b.Representative, nil
+	case *block.StateBlock:
+		return b.Representative, nil
 	default:
 		return nil, errors.New("bad representative block type")
 	}
 }
+
+// blockAccount returns the address of the account the block with the given
+// hash belongs to.
+func (l *Ledger) blockAccount(txn StoreTxn, hash block.Hash) (wallet.Address, error) {
+	info, err := txn.GetBlockInfo(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	return info.Address, nil
+}
+
+// openBlock returns the hash of the open block of the account with the given
+// address.
+func (l *Ledger) openBlock(txn StoreTxn, address wallet.Address) (block.Hash, error) {
+	info, err := txn.GetAddress(address)
+	if err != nil {
+		return block.Hash{}, err
+	}
+
+	return info.OpenBlock, nil
+}
+
+// rootSuccessor returns the hash of the block that follows the block with the
+// given hash in the chain of its account.
+func (l *Ledger) rootSuccessor(txn StoreTxn, hash block.Hash) (block.Hash, error) {
+	address, err := l.blockAccount(txn, hash)
+	if err != nil {
+		return block.Hash{}, err
+	}
+
+	return l.successor(txn, address, hash)
+}
+
+// successor returns the hash of the block that follows the block with the given
+// hash in the chain of the given account.
+func (l *Ledger) successor(txn StoreTxn, address wallet.Address, hash block.Hash) (block.Hash, error) {
+	info, err := txn.GetAddress(address)
+	if err != nil {
+		return block.Hash{}, err
+	}
+
+	for current := info.HeadBlock; !current.IsZero(); {
+		blk, err := txn.GetBlock(current)
+		if err != nil {
+			return block.Hash{}, err
+		}
+
+		prev := previous(blk)
+		if prev.Equal(hash) {
+			return current, nil
+		}
+		current = prev
+	}
+
+	return block.Hash{}, ErrNotFound
+}
+
+// balance returns the balance of the account as of the block with the given
+// hash.
+func (l *Ledger) balance(txn StoreTxn, hash block.Hash) (wallet.Balance, error) {
+	info, err := txn.GetBlockInfo(hash)
+	if err != nil {
+		return wallet.ZeroBalance, err
+	}
+
+	return info.Balance, nil
+}
+
+// storeBlock adds the given block to the store, together with its details.
+func storeBlock(txn StoreTxn, blk block.Block, info *BlockInfo) error {
+	if err := txn.AddBlock(blk); err != nil {
+		return err
+	}
+
+	return txn.SetBlockInfo(blk.Hash(), info)
+}
+
+// deleteBlock removes the block with the given hash from the store, together
+// with its details.
+func deleteBlock(txn StoreTxn, hash block.Hash) error {
+	if err := txn.DeleteBlockInfo(hash); err != nil {
+		return err
+	}
+
+	return txn.DeleteBlock(hash)
+}
+
+// previous returns the hash of the block that precedes the given block in its
+// account chain. A zero hash is returned for open blocks.
+func previous(blk block.Block) block.Hash {
+	switch b := blk.(type) {
+	case *block.OpenBlock:
+		return block.Hash{}
+	case *block.StateBlock:
+		return b.PreviousHash
+	default:
+		return b.Root()
+	}
+}
+
+// dependency returns the hash of the block the given block is waiting for, if
+// the given error indicates that the block is missing a dependency.
+func dependency(blk block.Block, err error) (block.Hash, bool) {
+	switch err {
+	case ErrMissingPrevious:
+		return blk.Root(), true
+	case ErrMissingSource:
+		switch b := blk.(type) {
+		case *block.OpenBlock:
+			return b.SourceHash, true
+		case *block.ReceiveBlock:
+			return b.SourceHash, true
+		case *block.StateBlock:
+			return b.Link, true
+		}
+	}
+
+	return block.Hash{}, false
+}
@@ -1,36 +1,35 @@
 package store
 
 import (
+	"bytes"
+	"crypto/rand"
 	"encoding/json"
+	"errors"
 	"io/ioutil"
-	"os"
 	"testing"
+	"time"
 
 	"github.com/alexbakker/gonano/nano/block"
+	"github.com/alexbakker/gonano/nano/crypto/ed25519"
 	"github.com/alexbakker/gonano/nano/store/genesis"
+	"github.com/alexbakker/gonano/nano/wallet"
 )
 
 type testLedger struct {
 	*Ledger
 	store Store
-	dir   string
 }
 
 func initTestLedger(t *testing.T) *testLedger {
-	dir, err := ioutil.TempDir("", "gonano_test_")
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	store, err := NewBadgerStore(dir)
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	ledger, err := NewLedger(store, LedgerOptions{
+	return initTestLedgerWithOptions(t, LedgerOptions{
 		GenesisBlock:   genesis.LiveBlock,
 		GenesisBalance: genesis.LiveBalance,
 	})
+}
+
+func initTestLedgerWithOptions(t *testing.T, opts LedgerOptions) *testLedger {
+	store := NewMemoryStore()
+	ledger, err := NewLedger(store, opts)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -38,7 +37,6 @@ func initTestLedger(t *testing.T) *testLedger {
 	return &testLedger{
 		Ledger: ledger,
 		store:  store,
-		dir:    dir,
 	}
 }
 
@@ -46,14 +44,23 @@ func (l *testLedger) Close(t *testing.T) {
 	if err := l.store.Close(); err != nil {
 		t.Error(err)
 	}
-	if err := os.RemoveAll(l.dir); err != nil {
-		t.Fatal(err)
-	}
 }
 
-func parseBlocks(t *testing.T, filename string) (blocks []block.Block) {
+type testBlocks struct {
+	Genesis *block.OpenBlock
+	Blocks  []block.Block
+	Forks   []block.Block
+}
+
+func parseBlocks(t *testing.T, filename string) []block.Block {
+	return parseTestBlocks(t, filename).Blocks
+}
+
+func parseTestBlocks(t *testing.T, filename string) *testBlocks {
 	type fileStruct struct {
-		Blocks []json.RawMessage `json:"blocks"`
+		Genesis json.RawMessage   `json:"genesis"`
+		Blocks  []json.RawMessage `json:"blocks"`
+		Forks   []json.RawMessage `json:"forks"`
 	}
 
 	data, err := ioutil.ReadFile(filename)
@@ -66,49 +73,820 @@ func parseBlocks(t *testing.T, filename string) (blocks []block.Block) {
 		t.Fatal(err)
 	}
 
+	var res testBlocks
+	if file.Genesis != nil {
+		res.Genesis = parseBlock(t, file.Genesis).(*block.OpenBlock)
+	}
 	for _, data := range file.Blocks {
-		var values map[string]interface{}
-		if err = json.Unmarshal(data, &values); err != nil {
+		res.Blocks = append(res.Blocks, parseBlock(t, data))
+	}
+	for _, data := range file.Forks {
+		res.Forks = append(res.Forks, parseBlock(t, data))
+	}
+
+	return &res
+}
+
+func parseBlock(t *testing.T, data []byte) block.Block {
+	var values map[string]interface{}
+	if err := json.Unmarshal(data, &values); err != nil {
+		t.Fatal(err)
+	}
+
+	id, ok := values["type"]
+	if !ok {
+		t.Fatalf("no 'type' key found in block")
+	}
+
+	var blk block.Block
+	switch id {
+	case "send":
+		blk = new(block.SendBlock)
+	case "receive":
+		blk = new(block.ReceiveBlock)
+	case "open":
+		blk = new(block.OpenBlock)
+	case "change":
+		blk = new(block.ChangeBlock)
+	case "state":
+		blk = new(block.StateBlock)
+	default:
+		t.Fatalf("unsupported block type: %s", id)
+	}
+
+	if err := json.Unmarshal(data, blk); err != nil {
+		t.Fatal(err)
+	}
+
+	return blk
+}
+
+// initChainsLedger initializes a test ledger with the genesis block from
+// testdata/chains.json. The blocks in that file are signed with the keys of
+// the first accounts of the following seed:
+// 676f6e616e6f207465737420736565642c20646f206e6f742075736521212121.
+func initChainsLedger(t *testing.T) (*testLedger, *testBlocks) {
+	blocks := parseTestBlocks(t, "./testdata/chains.json")
+	ledger := initTestLedgerWithOptions(t, LedgerOptions{
+		GenesisBlock:   blocks.Genesis,
+		GenesisBalance: wallet.ParseBalanceInts(0xffffffffffffffff, 0xffffffffffffffff),
+	})
+	return ledger, blocks
+}
+
+func TestLedgerBlocks(t *testing.T) {
+	ledger := initTestLedger(t)
+	defer ledger.Close(t)
+
+	blocks := parseBlocks(t, "./testdata/blocks.json")
+	for _, blk := range blocks {
+		if err := ledger.AddBlock(blk); err != nil {
 			t.Fatal(err)
 		}
+	}
+}
+
+func TestLedgerWorkThreshold(t *testing.T) {
+	const threshold = 0xff00000000000000
+	blocks := parseTestBlocks(t, "./testdata/chains.json")
+
+	// the work is not covered by the signature, so it can be replaced with
+	// work that only meets the lower threshold
+	blk := *blocks.Blocks[0].(*block.SendBlock)
+	for blk.Valid() || !blk.ValidThreshold(threshold) {
+		blk.Common.Work++
+	}
+
+	ledger, _ := initChainsLedger(t)
+	defer ledger.Close(t)
+	if err := ledger.AddBlock(&blk); err != ErrBadWork {
+		t.Fatalf("expected: %s, got: %v", ErrBadWork, err)
+	}
+
+	ledger = initTestLedgerWithOptions(t, LedgerOptions{
+		GenesisBlock:   blocks.Genesis,
+		GenesisBalance: wallet.ParseBalanceInts(0xffffffffffffffff, 0xffffffffffffffff),
+		WorkThreshold:  threshold,
+	})
+	defer ledger.Close(t)
+	if err := ledger.AddBlock(&blk); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLedgerFrontiers(t *testing.T) {
+	ledger := initTestLedger(t)
+	defer ledger.Close(t)
+
+	blocks := parseBlocks(t, "./testdata/blocks.json")
+	if err := ledger.AddBlocks(blocks); err != nil {
+		t.Fatal(err)
+	}
+
+	frontiers, err := ledger.GetFrontiers()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, frontier := range frontiers {
+		// every account should have exactly one frontier
+		if i > 0 && bytes.Compare(frontiers[i-1].Address, frontier.Address) >= 0 {
+			t.Fatalf("frontiers not sorted or duplicate frontier for: %s", frontier.Address)
+		}
 
-		id, ok := values["type"]
-		if !ok {
-			t.Fatalf("no 'type' key found in block")
+		chain, err := ledger.GetChain(frontier.Address, block.Hash{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(chain) == 0 || chain[0].Hash() != frontier.Hash {
+			t.Fatalf("frontier is not the head block of: %s", frontier.Address)
 		}
+	}
+}
+
+func TestLedgerUnchecked(t *testing.T) {
+	ledger := initTestLedger(t)
+	defer ledger.Close(t)
 
-		var blk block.Block
-		switch id {
-		case "send":
-			blk = new(block.SendBlock)
-		case "receive":
-			blk = new(block.ReceiveBlock)
-		case "open":
-			blk = new(block.OpenBlock)
-		case "change":
-			blk = new(block.ChangeBlock)
-		default:
-			t.Fatalf("unsupported block type: %s", id)
+	// add the blocks in reverse order, so that they all end up in the
+	// unchecked list until the first block is added
+	blocks := parseBlocks(t, "./testdata/blocks.json")
+	for i := len(blocks) - 1; i > 0; i-- {
+		if err := ledger.AddBlock(blocks[i]); err != ErrMissingPrevious {
+			t.Fatalf("expected: %s, got: %v", ErrMissingPrevious, err)
 		}
+	}
 
-		if err = json.Unmarshal(data, blk); err != nil {
+	count, err := ledger.CountUncheckedBlocks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != uint64(len(blocks)-1) {
+		t.Fatalf("expected %d unchecked blocks, got: %d", len(blocks)-1, count)
+	}
+
+	if err = ledger.AddBlock(blocks[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	if count, err = ledger.CountUncheckedBlocks(); err != nil {
+		t.Fatal(err)
+	}
+	if count != 0 {
+		t.Fatalf("expected 0 unchecked blocks, got: %d", count)
+	}
+
+	if count, err = ledger.CountBlocks(); err != nil {
+		t.Fatal(err)
+	}
+	if count != uint64(len(blocks)+1) {
+		t.Fatalf("expected %d blocks, got: %d", len(blocks)+1, count)
+	}
+}
+
+func TestLedgerUncheckedLimits(t *testing.T) {
+	opts := LedgerOptions{
+		GenesisBlock:       genesis.LiveBlock,
+		GenesisBalance:     genesis.LiveBalance,
+		MaxUncheckedBlocks: 1,
+	}
+	blocks := parseBlocks(t, "./testdata/blocks.json")
+
+	ledger := initTestLedgerWithOptions(t, opts)
+	defer ledger.Close(t)
+
+	if err := ledger.AddBlock(blocks[2]); err != ErrMissingPrevious {
+		t.Fatalf("expected: %s, got: %v", ErrMissingPrevious, err)
+	}
+	if err := ledger.AddBlock(blocks[1]); err != ErrUncheckedFull {
+		t.Fatalf("expected: %s, got: %v", ErrUncheckedFull, err)
+	}
+
+	now := time.Unix(0, 0)
+	opts.UncheckedExpiry = time.Minute
+	opts.Clock = func() time.Time {
+		return now
+	}
+	expLedger := initTestLedgerWithOptions(t, opts)
+	defer expLedger.Close(t)
+
+	if err := expLedger.AddBlock(blocks[2]); err != ErrMissingPrevious {
+		t.Fatalf("expected: %s, got: %v", ErrMissingPrevious, err)
+	}
+
+	// the list is still full right before the first block expires
+	now = now.Add(opts.UncheckedExpiry)
+	if err := expLedger.AddBlock(blocks[1]); err != ErrUncheckedFull {
+		t.Fatalf("expected: %s, got: %v", ErrUncheckedFull, err)
+	}
+
+	// once the first block has expired, there's room for another one
+	now = now.Add(time.Second)
+	if err := expLedger.AddBlock(blocks[1]); err != ErrMissingPrevious {
+		t.Fatalf("expected: %s, got: %v", ErrMissingPrevious, err)
+	}
+
+	now = now.Add(opts.UncheckedExpiry * 2)
+	if err := expLedger.PurgeUncheckedBlocks(); err != nil {
+		t.Fatal(err)
+	}
+
+	count, err := expLedger.CountUncheckedBlocks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 0 {
+		t.Fatalf("expected 0 unchecked blocks, got: %d", count)
+	}
+}
+
+func TestLedgerFork(t *testing.T) {
+	ledger, blocks := initChainsLedger(t)
+	defer ledger.Close(t)
+
+	if err := ledger.AddBlocks(blocks.Blocks); err != nil {
+		t.Fatal(err)
+	}
+
+	existing := blocks.Blocks[0]
+	fork := blocks.Forks[0]
+	err := ledger.AddBlock(fork)
+	forkErr, ok := err.(*ErrFork)
+	if !ok {
+		t.Fatalf("expected a fork, got: %v", err)
+	}
+	if forkErr.Root != fork.Root() || forkErr.Existing != existing.Hash() || forkErr.Fork != fork.Hash() {
+		t.Fatalf("unexpected fork: %s", forkErr)
+	}
+
+	forks, err := ledger.GetForks(fork.Root())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(forks) != 2 {
+		t.Fatalf("expected 2 competing blocks, got: %d", len(forks))
+	}
+	for _, blk := range forks {
+		if blk.Hash() != existing.Hash() && blk.Hash() != fork.Hash() {
+			t.Fatalf("unexpected competing block: %s", blk.Hash())
+		}
+	}
+
+	// the fork should not have been added to the ledger
+	if err = ledger.store.View(func(txn StoreTxn) error {
+		found, err := txn.HasBlock(fork.Hash())
+		if err != nil {
+			return err
+		}
+		if found {
+			t.Fatalf("fork was added to the ledger")
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// both competing blocks map to the block in the ledger
+	for _, blk := range []block.Block{existing, fork} {
+		rootBlk, err := ledger.GetRootBlock(blk)
+		if err != nil {
 			t.Fatal(err)
 		}
+		if rootBlk.Hash() != existing.Hash() {
+			t.Fatalf("unexpected root block: %s", rootBlk.Hash())
+		}
+	}
+
+	// the last block of a chain doesn't compete with anything yet
+	last := blocks.Blocks[len(blocks.Blocks)-1]
+	if err := ledger.Rollback(last.Hash()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ledger.GetRootBlock(last); err != ErrNotFound {
+		t.Fatalf("expected: %s, got: %v", ErrNotFound, err)
+	}
+}
+
+func TestLedgerRollback(t *testing.T) {
+	ledger, blocks := initChainsLedger(t)
+	defer ledger.Close(t)
+
+	if err := ledger.AddBlocks(blocks.Blocks); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ledger.Rollback(blocks.Genesis.Hash()); err != ErrRollbackGenesis {
+		t.Fatalf("expected the genesis rollback to fail, got: %v", err)
+	}
+
+	genesis := blocks.Genesis.Address
+	open := blocks.Blocks[1].(*block.OpenBlock)
+	send := blocks.Blocks[5].(*block.SendBlock)
+	receive := blocks.Blocks[6].(*block.ReceiveBlock)
+	maxBalance := wallet.ParseBalanceInts(0xffffffffffffffff, 0xffffffffffffffff)
+
+	// rolling back the last receive block should make its source pending again
+	if err := ledger.Rollback(receive.Hash()); err != nil {
+		t.Fatal(err)
+	}
+	if err := ledger.store.View(func(txn StoreTxn) error {
+		pending, err := txn.GetPending(genesis, send.Hash())
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(pending.Address, open.Address) {
+			t.Fatalf("unexpected pending source: %s", pending.Address)
+		}
+		if !pending.Amount.Equal(wallet.ParseBalanceInts(0, 500)) {
+			t.Fatalf("unexpected pending amount: %s", pending.Amount)
+		}
+
+		info, err := txn.GetAddress(genesis)
+		if err != nil {
+			return err
+		}
+		if !info.HeadBlock.Equal(blocks.Blocks[3].Hash()) {
+			t.Fatalf("unexpected head block: %s", info.HeadBlock)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// rolling back the first send block of the genesis account should also roll
+	// back the account that received it
+	if err := ledger.AddBlock(receive); err != nil {
+		t.Fatal(err)
+	}
+	if err := ledger.Rollback(blocks.Blocks[0].Hash()); err != nil {
+		t.Fatal(err)
+	}
+
+	count, err := ledger.CountBlocks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Fatalf("expected only the genesis block to remain, got: %d blocks", count)
+	}
+
+	if err := ledger.store.View(func(txn StoreTxn) error {
+		if _, err := txn.GetAddress(open.Address); err == nil {
+			t.Fatalf("account was not removed")
+		}
+		for _, blk := range blocks.Blocks {
+			if _, err := txn.GetBlockInfo(blk.Hash()); err != ErrNotFound {
+				t.Fatalf("block info of %s was not removed: %v", blk.Hash(), err)
+			}
+		}
+
+		info, err := txn.GetAddress(genesis)
+		if err != nil {
+			return err
+		}
+		if !info.HeadBlock.Equal(blocks.Genesis.Hash()) {
+			t.Fatalf("unexpected head block: %s", info.HeadBlock)
+		}
+		if !info.Balance.Equal(maxBalance) {
+			t.Fatalf("unexpected genesis balance: %s", info.Balance)
+		}
+
+		weight, err := txn.GetRepresentation(genesis)
+		if err != nil {
+			return err
+		}
+		if !weight.Equal(maxBalance) {
+			t.Fatalf("unexpected genesis voting weight: %s", weight)
+		}
 
-		blocks = append(blocks, blk)
+		weight, err = txn.GetRepresentation(open.Address)
+		if err != nil {
+			return err
+		}
+		if !weight.Equal(wallet.ZeroBalance) {
+			t.Fatalf("unexpected voting weight: %s", weight)
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
 	}
 
-	return
+	frontiers, err := ledger.GetFrontiers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(frontiers) != 1 {
+		t.Fatalf("expected 1 frontier, got: %d", len(frontiers))
+	}
 }
 
-func TestLedgerBlocks(t *testing.T) {
-	ledger := initTestLedger(t)
+func TestLedgerStateBlocks(t *testing.T) {
+	ledger, blocks := initChainsLedger(t)
 	defer ledger.Close(t)
 
-	blocks := parseBlocks(t, "./testdata/blocks.json")
-	for _, blk := range blocks {
+	if err := ledger.AddBlocks(blocks.Blocks); err != nil {
+		t.Fatal(err)
+	}
+
+	// the state blocks send from the genesis account to a new account, which
+	// changes its representative and sends part of it to an existing account
+	stateBlocks := parseBlocks(t, "./testdata/state.json")
+	for _, blk := range stateBlocks {
 		if err := ledger.AddBlock(blk); err != nil {
 			t.Fatal(err)
 		}
 	}
+
+	genesis := blocks.Genesis.Address
+	first := stateBlocks[1].(*block.StateBlock).Address
+	second := stateBlocks[4].(*block.StateBlock).Address
+	maxBalance := wallet.ParseBalanceInts(0xffffffffffffffff, 0xffffffffffffffff)
+
+	type accountState struct {
+		address wallet.Address
+		balance wallet.Balance
+		weight  wallet.Balance
+	}
+	check := func(expected []accountState) {
+		if err := ledger.store.View(func(txn StoreTxn) error {
+			for _, e := range expected {
+				info, err := txn.GetAddress(e.address)
+				if err != nil {
+					return err
+				}
+				if !info.Balance.Equal(e.balance) {
+					t.Fatalf("unexpected balance: %s", info.Balance)
+				}
+
+				weight, err := txn.GetRepresentation(e.address)
+				if err != nil {
+					return err
+				}
+				if !weight.Equal(e.weight) {
+					t.Fatalf("unexpected voting weight: %s", weight)
+				}
+			}
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	check([]accountState{
+		{genesis, maxBalance.Sub(wallet.ParseBalanceInts(0, 2600)), maxBalance.Sub(wallet.ParseBalanceInts(0, 2600))},
+		{first, wallet.ParseBalanceInts(0, 40), wallet.ZeroBalance},
+		{second, wallet.ParseBalanceInts(0, 2560), wallet.ParseBalanceInts(0, 2600)},
+	})
+
+	// rolling back the send to the new account should remove that account and
+	// the receive of its send
+	if err := ledger.Rollback(stateBlocks[0].Hash()); err != nil {
+		t.Fatal(err)
+	}
+
+	count, err := ledger.CountBlocks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != uint64(len(blocks.Blocks)+1) {
+		t.Fatalf("unexpected amount of blocks: %d", count)
+	}
+
+	check([]accountState{
+		{genesis, maxBalance.Sub(wallet.ParseBalanceInts(0, 2500)), maxBalance},
+		{second, wallet.ParseBalanceInts(0, 2500), wallet.ZeroBalance},
+	})
+
+	if err := ledger.store.View(func(txn StoreTxn) error {
+		if _, err := txn.GetAddress(first); err == nil {
+			t.Fatalf("account was not removed")
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLedgerStateReceiveWithoutLink(t *testing.T) {
+	const threshold = 0xff00000000000000
+	blocks := parseTestBlocks(t, "./testdata/chains.json")
+	ledger := initTestLedgerWithOptions(t, LedgerOptions{
+		GenesisBlock:   blocks.Genesis,
+		GenesisBalance: wallet.ParseBalanceInts(0xffffffffffffffff, 0xffffffffffffffff),
+		WorkThreshold:  threshold,
+	})
+	defer ledger.Close(t)
+
+	// an open block that increases the balance without a source block in the
+	// link field
+	pub, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blk := &block.StateBlock{
+		Address:        wallet.Address(pub),
+		Representative: wallet.Address(pub),
+		Balance:        wallet.ParseBalanceInts(0, 1),
+	}
+	for !blk.ValidThreshold(threshold) {
+		blk.Common.Work++
+	}
+	hash := blk.Hash()
+	copy(blk.Common.Signature[:], ed25519.Sign(key, hash[:]))
+
+	if err := ledger.AddBlock(blk); err != ErrMissingLink {
+		t.Fatalf("expected: %s, got: %v", ErrMissingLink, err)
+	}
+
+	// the block can never be added, so it should not be kept around
+	count, err := ledger.CountUncheckedBlocks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 0 {
+		t.Fatalf("expected 0 unchecked blocks, got: %d", count)
+	}
+}
+
+func TestLedgerVoteSequence(t *testing.T) {
+	ledger, blocks := initChainsLedger(t)
+	defer ledger.Close(t)
+
+	address := blocks.Genesis.Address
+	for i := uint64(1); i <= 3; i++ {
+		sequence, err := ledger.NextVoteSequence(address)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sequence != i {
+			t.Fatalf("expected sequence %d, got: %d", i, sequence)
+		}
+	}
+
+	// sequence numbers are kept track of per representative
+	other := blocks.Blocks[1].(*block.OpenBlock).Address
+	sequence, err := ledger.NextVoteSequence(other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sequence != 1 {
+		t.Fatalf("expected sequence 1, got: %d", sequence)
+	}
+
+	// only the reserved range is written to the store
+	err = ledger.store.View(func(txn StoreTxn) error {
+		stored, err := txn.GetVoteSequence(address)
+		if err != nil {
+			return err
+		}
+		if stored != voteSequenceReserve {
+			t.Fatalf("expected a stored sequence of %d, got: %d", voteSequenceReserve, stored)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// after a restart, the numbers that may have been handed out are skipped
+	restarted, err := NewLedger(ledger.store, ledger.opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sequence, err = restarted.NextVoteSequence(address); err != nil {
+		t.Fatal(err)
+	}
+	if sequence != voteSequenceReserve+1 {
+		t.Fatalf("expected sequence %d, got: %d", voteSequenceReserve+1, sequence)
+	}
+}
+
+func TestLedgerAccountHistory(t *testing.T) {
+	ledger, blocks := initChainsLedger(t)
+	defer ledger.Close(t)
+
+	if err := ledger.AddBlocks(blocks.Blocks); err != nil {
+		t.Fatal(err)
+	}
+	stateBlocks := parseBlocks(t, "./testdata/state.json")
+	if err := ledger.AddBlocks(stateBlocks); err != nil {
+		t.Fatal(err)
+	}
+
+	genesis := blocks.Genesis.Address
+	first := stateBlocks[1].(*block.StateBlock).Address
+	second := stateBlocks[4].(*block.StateBlock).Address
+	raw := func(n uint64) wallet.Balance {
+		return wallet.ParseBalanceInts(0, n)
+	}
+
+	type historyEntry struct {
+		blk     block.Block
+		typ     HistoryType
+		account wallet.Address
+		amount  wallet.Balance
+	}
+	check := func(address wallet.Address, from block.Hash, count int, expected []historyEntry) {
+		entries, err := ledger.AccountHistory(address, from, count)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != len(expected) {
+			t.Fatalf("expected %d history entries, got: %d", len(expected), len(entries))
+		}
+
+		for i, e := range expected {
+			entry := entries[i]
+			if entry.Hash != e.blk.Hash() || entry.Type != e.typ || !bytes.Equal(entry.Account, e.account) || !entry.Amount.Equal(e.amount) {
+				t.Fatalf("unexpected history entry %d: %s %s %s %s", i, entry.Hash, entry.Type, entry.Account, entry.Amount.UnitString("raw", 0))
+			}
+		}
+	}
+
+	check(second, block.Hash{}, 0, []historyEntry{
+		{stateBlocks[4], HistoryReceive, first, raw(60)},
+		{blocks.Blocks[5], HistorySend, genesis, raw(500)},
+		{blocks.Blocks[4], HistoryReceive, genesis, raw(2000)},
+		{blocks.Blocks[2], HistoryChange, genesis, wallet.ZeroBalance},
+		{blocks.Blocks[1], HistoryReceive, genesis, raw(1000)},
+	})
+	check(first, block.Hash{}, 0, []historyEntry{
+		{stateBlocks[3], HistorySend, second, raw(60)},
+		{stateBlocks[2], HistoryChange, second, wallet.ZeroBalance},
+		{stateBlocks[1], HistoryReceive, genesis, raw(100)},
+	})
+	check(genesis, blocks.Blocks[3].Hash(), 2, []historyEntry{
+		{blocks.Blocks[3], HistorySend, second, raw(2000)},
+		{blocks.Blocks[0], HistorySend, second, raw(1000)},
+	})
+
+	if _, err := ledger.AccountHistory(genesis, stateBlocks[3].Hash(), 0); err != ErrNotInChain {
+		t.Fatalf("expected: %s, got: %v", ErrNotInChain, err)
+	}
+
+	info, err := ledger.AccountInfo(first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.HeadBlock != stateBlocks[3].Hash() || info.OpenBlock != stateBlocks[1].Hash() || !bytes.Equal(info.Representative, second) || !info.Balance.Equal(raw(40)) {
+		t.Fatalf("unexpected account info")
+	}
+
+	balance, err := ledger.Balance(second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !balance.Equal(raw(2560)) {
+		t.Fatalf("expected a balance of 2560 raw, got: %s", balance.UnitString("raw", 0))
+	}
+
+	account, err := ledger.BlockAccount(blocks.Blocks[2].Hash())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(account, second) {
+		t.Fatalf("unexpected block account: %s", account)
+	}
+
+	// accounts that haven't been opened yet
+	missing := make(wallet.Address, wallet.AddressSize)
+	if _, err = ledger.AccountInfo(missing); err != ErrMissingAccount {
+		t.Fatalf("expected: %s, got: %v", ErrMissingAccount, err)
+	}
+	if balance, err = ledger.Balance(missing); err != nil || !balance.Equal(wallet.ZeroBalance) {
+		t.Fatalf("expected a zero balance, got: %s, %v", balance, err)
+	}
+}
+
+func TestLedgerPending(t *testing.T) {
+	ledger, blocks := initChainsLedger(t)
+	defer ledger.Close(t)
+
+	// send twice from the genesis account without receiving
+	sends := []block.Block{blocks.Blocks[0], blocks.Blocks[3]}
+	if err := ledger.AddBlocks(sends); err != nil {
+		t.Fatal(err)
+	}
+
+	genesis := blocks.Genesis.Address
+	destination := blocks.Blocks[0].(*block.SendBlock).Destination
+
+	pending, err := ledger.Pending(destination, wallet.ZeroBalance)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 2 {
+		t.Fatalf("expected 2 pending blocks, got: %d", len(pending))
+	}
+	for i, blk := range pending {
+		if i > 0 && bytes.Compare(pending[i-1].Hash[:], blk.Hash[:]) >= 0 {
+			t.Fatalf("pending blocks not sorted by hash")
+		}
+		if !bytes.Equal(blk.Address, genesis) {
+			t.Fatalf("unexpected source account: %s", blk.Address)
+		}
+	}
+
+	pending, err = ledger.Pending(destination, wallet.ParseBalanceInts(0, 2000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 1 || pending[0].Hash != sends[1].Hash() || !pending[0].Amount.Equal(wallet.ParseBalanceInts(0, 2000)) {
+		t.Fatalf("unexpected pending blocks above the threshold")
+	}
+
+	// receiving removes the block from the pending list
+	if err = ledger.AddBlock(blocks.Blocks[1]); err != nil {
+		t.Fatal(err)
+	}
+	if pending, err = ledger.Pending(destination, wallet.ZeroBalance); err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 1 || pending[0].Hash != sends[1].Hash() {
+		t.Fatalf("received block is still pending")
+	}
+}
+
+// failingStore is a store of which lookups of addresses, or of the unchecked
+// blocks that wait for the given parent, fail with err.
+type failingStore struct {
+	Store
+	err       error
+	addresses bool
+	parent    block.Hash
+}
+
+type failingStoreTxn struct {
+	StoreTxn
+	store *failingStore
+}
+
+func (s *failingStore) View(fn func(txn StoreTxn) error) error {
+	return s.Store.View(func(txn StoreTxn) error {
+		return fn(&failingStoreTxn{txn, s})
+	})
+}
+
+func (s *failingStore) Update(fn func(txn StoreTxn) error) error {
+	return s.Store.Update(func(txn StoreTxn) error {
+		return fn(&failingStoreTxn{txn, s})
+	})
+}
+
+func (t *failingStoreTxn) GetAddress(address wallet.Address) (*AddressInfo, error) {
+	if t.store.addresses {
+		return nil, t.store.err
+	}
+	return t.StoreTxn.GetAddress(address)
+}
+
+func (t *failingStoreTxn) GetUncheckedBlocks(parentHash block.Hash) ([]*UncheckedBlock, error) {
+	if parentHash.Equal(t.store.parent) {
+		return nil, t.store.err
+	}
+	return t.StoreTxn.GetUncheckedBlocks(parentHash)
+}
+
+func TestLedgerChainErrors(t *testing.T) {
+	ledger, _ := initChainsLedger(t)
+	defer ledger.Close(t)
+
+	missing := make(wallet.Address, wallet.AddressSize)
+	if _, err := ledger.GetChain(missing, block.Hash{}); err != ErrMissingAccount {
+		t.Fatalf("expected: %s, got: %v", ErrMissingAccount, err)
+	}
+
+	// store failures should not be mistaken for missing accounts
+	errTest := errors.New("test error")
+	ledger.db = &failingStore{Store: ledger.store, err: errTest, addresses: true}
+	if _, err := ledger.GetChain(missing, block.Hash{}); err != errTest {
+		t.Fatalf("expected: %s, got: %v", errTest, err)
+	}
+}
+
+func TestLedgerUncheckedCount(t *testing.T) {
+	ledger := initTestLedger(t)
+	defer ledger.Close(t)
+
+	blocks := parseBlocks(t, "./testdata/blocks.json")
+	if err := ledger.AddBlock(blocks[1]); err != ErrMissingPrevious {
+		t.Fatalf("expected: %s, got: %v", ErrMissingPrevious, err)
+	}
+
+	// fail while processing the unchecked blocks that follow the block that
+	// was waited for, so that the removal from the unchecked list is rolled
+	// back
+	errTest := errors.New("test error")
+	ledger.db = &failingStore{Store: ledger.store, err: errTest, parent: blocks[1].Hash()}
+	if err := ledger.AddBlock(blocks[0]); err != errTest {
+		t.Fatalf("expected: %s, got: %v", errTest, err)
+	}
+
+	count, err := ledger.CountUncheckedBlocks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 || ledger.unchecked != count {
+		t.Fatalf("expected 1 unchecked block, got: %d in the store and %d in the counter", count, ledger.unchecked)
+	}
 }
@@ -0,0 +1,656 @@
+package store
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alexbakker/gonano/nano/block"
+	"github.com/alexbakker/gonano/nano/wallet"
+)
+
+var (
+	ErrReadOnly = errors.New("the transaction is read-only")
+)
+
+// MemoryStore represents a Nano block lattice store that is kept in memory. It
+// uses the same key layout as BadgerStore. Update transactions are serialized
+// and block any View transactions until they are done.
+type MemoryStore struct {
+	mutex sync.RWMutex
+	items map[string]memoryItem
+	// keys contains the keys of items in sorted order
+	keys []string
+}
+
+type MemoryStoreTxn struct {
+	store  *MemoryStore
+	update bool
+	// writes contains the changes made in this transaction. Deleted keys are
+	// set to nil.
+	writes map[string]*memoryItem
+}
+
+type memoryItem struct {
+	meta  byte
+	value []byte
+}
+
+// NewMemoryStore initializes an empty in-memory store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{items: make(map[string]memoryItem)}
+}
+
+// Close releases the contents of the store.
+func (s *MemoryStore) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.items = make(map[string]memoryItem)
+	s.keys = nil
+	return nil
+}
+
+// Purge is a no-op, as deleted keys are removed from memory immediately.
+func (s *MemoryStore) Purge() error {
+	return nil
+}
+
+func (s *MemoryStore) View(fn func(txn StoreTxn) error) error {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return fn(&MemoryStoreTxn{store: s})
+}
+
+func (s *MemoryStore) Update(fn func(txn StoreTxn) error) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	txn := &MemoryStoreTxn{
+		store:  s,
+		update: true,
+		writes: make(map[string]*memoryItem),
+	}
+	if err := fn(txn); err != nil {
+		return err
+	}
+
+	s.commit(txn.writes)
+	return nil
+}
+
+func (s *MemoryStore) commit(writes map[string]*memoryItem) {
+	for key, item := range writes {
+		_, exists := s.items[key]
+		i := sort.SearchStrings(s.keys, key)
+
+		if item == nil {
+			if exists {
+				delete(s.items, key)
+				s.keys = append(s.keys[:i], s.keys[i+1:]...)
+			}
+			continue
+		}
+
+		if !exists {
+			s.keys = append(s.keys, "")
+			copy(s.keys[i+1:], s.keys[i:])
+			s.keys[i] = key
+		}
+		s.items[key] = *item
+	}
+}
+
+func (t *MemoryStoreTxn) get(key []byte) (*memoryItem, error) {
+	if item, ok := t.writes[string(key)]; ok {
+		if item == nil {
+			return nil, ErrNotFound
+		}
+		return item, nil
+	}
+
+	item, ok := t.store.items[string(key)]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &item, nil
+}
+
+func (t *MemoryStoreTxn) has(key []byte) bool {
+	_, err := t.get(key)
+	return err == nil
+}
+
+func (t *MemoryStoreTxn) set(key []byte, value []byte, meta byte) error {
+	if !t.update {
+		return ErrReadOnly
+	}
+
+	t.writes[string(key)] = &memoryItem{
+		meta:  meta,
+		value: append([]byte(nil), value...),
+	}
+	return nil
+}
+
+func (t *MemoryStoreTxn) delete(key []byte) error {
+	if !t.update {
+		return ErrReadOnly
+	}
+
+	t.writes[string(key)] = nil
+	return nil
+}
+
+// iterate calls the given function for every item with the given key prefix,
+// in order of their key. If fn returns an error, the iteration is stopped and
+// that error is returned.
+func (t *MemoryStoreTxn) iterate(prefix []byte, fn func(key []byte, item *memoryItem) error) error {
+	return t.iterateFrom(prefix, prefix, fn)
+}
+
+// iterateFrom is like iterate, but skips the items with a key that is smaller
+// than the given start key.
+func (t *MemoryStoreTxn) iterateFrom(prefix []byte, start []byte, fn func(key []byte, item *memoryItem) error) error {
+	p, s := string(prefix), string(start)
+
+	var keys []string
+	for i := sort.SearchStrings(t.store.keys, s); i < len(t.store.keys); i++ {
+		if !strings.HasPrefix(t.store.keys[i], p) {
+			break
+		}
+		keys = append(keys, t.store.keys[i])
+	}
+	for key := range t.writes {
+		if strings.HasPrefix(key, p) && key >= s {
+			if _, ok := t.store.items[key]; !ok {
+				keys = append(keys, key)
+			}
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		// skip items that were deleted in this transaction
+		item, err := t.get([]byte(key))
+		if err != nil {
+			continue
+		}
+
+		if err := fn([]byte(key), item); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (t *MemoryStoreTxn) count(prefix []byte) (uint64, error) {
+	var count uint64
+	err := t.iterate(prefix, func(key []byte, item *memoryItem) error {
+		count++
+		return nil
+	})
+	return count, err
+}
+
+func memoryKey(prefix byte, parts ...[]byte) []byte {
+	key := []byte{prefix}
+	for _, part := range parts {
+		key = append(key, part...)
+	}
+	return key
+}
+
+func memoryBlock(item *memoryItem) (block.Block, error) {
+	blk, err := block.New(item.meta)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := blk.UnmarshalBinary(item.value); err != nil {
+		return nil, err
+	}
+
+	return blk, nil
+}
+
+// Empty reports whether the store is empty or not.
+func (t *MemoryStoreTxn) Empty() (bool, error) {
+	count, err := t.count([]byte{idPrefixBlock})
+	return count == 0, err
+}
+
+// AddBlock adds the given block to the store.
+func (t *MemoryStoreTxn) AddBlock(blk block.Block) error {
+	hash := blk.Hash()
+	blockBytes, err := blk.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	key := memoryKey(idPrefixBlock, hash[:])
+	if t.has(key) {
+		return ErrBlockExists
+	}
+
+	return t.set(key, blockBytes, blk.ID())
+}
+
+// GetBlock retrieves the block with the given hash from the store.
+func (t *MemoryStoreTxn) GetBlock(hash block.Hash) (block.Block, error) {
+	item, err := t.get(memoryKey(idPrefixBlock, hash[:]))
+	if err != nil {
+		return nil, err
+	}
+
+	return memoryBlock(item)
+}
+
+func (t *MemoryStoreTxn) DeleteBlock(hash block.Hash) error {
+	return t.delete(memoryKey(idPrefixBlock, hash[:]))
+}
+
+// HasBlock reports whether the store contains a block with the given hash.
+func (t *MemoryStoreTxn) HasBlock(hash block.Hash) (bool, error) {
+	return t.has(memoryKey(idPrefixBlock, hash[:])), nil
+}
+
+// WalkBlocks calls the given function for every block in the store with a hash
+// that is not smaller than the given start hash, in order of their hash. If fn
+// returns an error, the walk is stopped and that error is returned.
+func (t *MemoryStoreTxn) WalkBlocks(start block.Hash, fn func(blk block.Block) error) error {
+	prefix := []byte{idPrefixBlock}
+	return t.iterateFrom(prefix, memoryKey(idPrefixBlock, start[:]), func(key []byte, item *memoryItem) error {
+		blk, err := memoryBlock(item)
+		if err != nil {
+			return err
+		}
+
+		return fn(blk)
+	})
+}
+
+// CountBlocks returns the total amount of blocks in the store.
+func (t *MemoryStoreTxn) CountBlocks() (uint64, error) {
+	return t.count([]byte{idPrefixBlock})
+}
+
+// SetBlockInfo stores the given details of the block with the given hash.
+func (t *MemoryStoreTxn) SetBlockInfo(hash block.Hash, info *BlockInfo) error {
+	infoBytes, err := info.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	return t.set(memoryKey(idPrefixBlockInfo, hash[:]), infoBytes, 0)
+}
+
+// GetBlockInfo retrieves the details of the block with the given hash.
+func (t *MemoryStoreTxn) GetBlockInfo(hash block.Hash) (*BlockInfo, error) {
+	item, err := t.get(memoryKey(idPrefixBlockInfo, hash[:]))
+	if err != nil {
+		return nil, err
+	}
+
+	var info BlockInfo
+	if err := info.UnmarshalBinary(item.value); err != nil {
+		return nil, err
+	}
+
+	return &info, nil
+}
+
+func (t *MemoryStoreTxn) DeleteBlockInfo(hash block.Hash) error {
+	return t.delete(memoryKey(idPrefixBlockInfo, hash[:]))
+}
+
+func (t *MemoryStoreTxn) AddAddress(address wallet.Address, info *AddressInfo) error {
+	infoBytes, err := info.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	key := memoryKey(idPrefixAddress, address)
+	if t.has(key) {
+		return ErrAddressExists
+	}
+
+	return t.set(key, infoBytes, 0)
+}
+
+func (t *MemoryStoreTxn) GetAddress(address wallet.Address) (*AddressInfo, error) {
+	item, err := t.get(memoryKey(idPrefixAddress, address))
+	if err != nil {
+		return nil, err
+	}
+
+	var info AddressInfo
+	if err := info.UnmarshalBinary(item.value); err != nil {
+		return nil, err
+	}
+
+	return &info, nil
+}
+
+func (t *MemoryStoreTxn) UpdateAddress(address wallet.Address, info *AddressInfo) error {
+	infoBytes, err := info.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	return t.set(memoryKey(idPrefixAddress, address), infoBytes, 0)
+}
+
+func (t *MemoryStoreTxn) DeleteAddress(address wallet.Address) error {
+	return t.delete(memoryKey(idPrefixAddress, address))
+}
+
+// WalkAddresses calls the given function for every account in the store with
+// an address that is not smaller than the given start address, in order of
+// their address. If fn returns an error, the walk is stopped and that error is
+// returned.
+func (t *MemoryStoreTxn) WalkAddresses(start wallet.Address, fn func(address wallet.Address, info *AddressInfo) error) error {
+	prefix := []byte{idPrefixAddress}
+	return t.iterateFrom(prefix, memoryKey(idPrefixAddress, start), func(key []byte, item *memoryItem) error {
+		var info AddressInfo
+		if err := info.UnmarshalBinary(item.value); err != nil {
+			return err
+		}
+
+		return fn(wallet.Address(append([]byte(nil), key[1:]...)), &info)
+	})
+}
+
+func (t *MemoryStoreTxn) AddFrontier(frontier *block.Frontier) error {
+	key := memoryKey(idPrefixFrontier, frontier.Hash[:])
+	if t.has(key) {
+		return ErrFrontierExists
+	}
+
+	return t.set(key, frontier.Address, 0)
+}
+
+func (t *MemoryStoreTxn) GetFrontier(hash block.Hash) (*block.Frontier, error) {
+	item, err := t.get(memoryKey(idPrefixFrontier, hash[:]))
+	if err != nil {
+		return nil, err
+	}
+
+	address := append(wallet.Address(nil), item.value...)
+	return &block.Frontier{Address: address, Hash: hash}, nil
+}
+
+func (t *MemoryStoreTxn) GetFrontiers() ([]*block.Frontier, error) {
+	var frontiers []*block.Frontier
+	err := t.iterate([]byte{idPrefixFrontier}, func(key []byte, item *memoryItem) error {
+		var frontier block.Frontier
+		frontier.Address = append(wallet.Address(nil), item.value...)
+		copy(frontier.Hash[:], key[1:])
+
+		frontiers = append(frontiers, &frontier)
+		return nil
+	})
+
+	return frontiers, err
+}
+
+func (t *MemoryStoreTxn) DeleteFrontier(hash block.Hash) error {
+	return t.delete(memoryKey(idPrefixFrontier, hash[:]))
+}
+
+func (t *MemoryStoreTxn) CountFrontiers() (uint64, error) {
+	return t.count([]byte{idPrefixFrontier})
+}
+
+func (t *MemoryStoreTxn) AddPending(destination wallet.Address, hash block.Hash, pending *Pending) error {
+	pendingBytes, err := pending.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	key := memoryKey(idPrefixPending, destination, hash[:])
+	if t.has(key) {
+		return ErrPendingExists
+	}
+
+	return t.set(key, pendingBytes, 0)
+}
+
+func (t *MemoryStoreTxn) GetPending(destination wallet.Address, hash block.Hash) (*Pending, error) {
+	item, err := t.get(memoryKey(idPrefixPending, destination, hash[:]))
+	if err != nil {
+		return nil, err
+	}
+
+	var pending Pending
+	if err := pending.UnmarshalBinary(item.value); err != nil {
+		return nil, err
+	}
+
+	return &pending, nil
+}
+
+// GetPendings retrieves all pending transactions of the given destination, in
+// order of their send block hash.
+func (t *MemoryStoreTxn) GetPendings(destination wallet.Address) ([]*PendingBlock, error) {
+	var blocks []*PendingBlock
+	err := t.iterate(memoryKey(idPrefixPending, destination), func(key []byte, item *memoryItem) error {
+		var blk PendingBlock
+		if err := blk.Pending.UnmarshalBinary(item.value); err != nil {
+			return err
+		}
+		copy(blk.Hash[:], key[1+wallet.AddressSize:])
+
+		blocks = append(blocks, &blk)
+		return nil
+	})
+
+	return blocks, err
+}
+
+func (t *MemoryStoreTxn) DeletePending(destination wallet.Address, hash block.Hash) error {
+	return t.delete(memoryKey(idPrefixPending, destination, hash[:]))
+}
+
+func (t *MemoryStoreTxn) setRepresentation(address wallet.Address, amount wallet.Balance) error {
+	return t.set(memoryKey(idPrefixRepresentation, address), amount.Bytes(binary.BigEndian), 0)
+}
+
+func (t *MemoryStoreTxn) AddRepresentation(address wallet.Address, amount wallet.Balance) error {
+	oldAmount, err := t.GetRepresentation(address)
+	if err != nil {
+		return err
+	}
+
+	return t.setRepresentation(address, oldAmount.Add(amount))
+}
+
+func (t *MemoryStoreTxn) SubRepresentation(address wallet.Address, amount wallet.Balance) error {
+	oldAmount, err := t.GetRepresentation(address)
+	if err != nil {
+		return err
+	}
+
+	return t.setRepresentation(address, oldAmount.Sub(amount))
+}
+
+func (t *MemoryStoreTxn) GetRepresentation(address wallet.Address) (wallet.Balance, error) {
+	item, err := t.get(memoryKey(idPrefixRepresentation, address))
+	if err != nil {
+		if err == ErrNotFound {
+			return wallet.ZeroBalance, nil
+		}
+		return wallet.ZeroBalance, err
+	}
+
+	var amount wallet.Balance
+	if err := amount.UnmarshalBinary(item.value); err != nil {
+		return wallet.ZeroBalance, err
+	}
+
+	return amount, nil
+}
+
+func (t *MemoryStoreTxn) AddUncheckedBlock(parentHash block.Hash, blk *UncheckedBlock) error {
+	blockBytes, err := blk.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	hash := blk.Block.Hash()
+	key := memoryKey(idPrefixUnchecked, parentHash[:], hash[:])
+	if t.has(key) {
+		return ErrUncheckedExists
+	}
+
+	if err := t.set(key, blockBytes, 0); err != nil {
+		return err
+	}
+	return t.set(memoryKey(idPrefixUncheckedExpiry, uncheckedTimestamp(blk.Timestamp), parentHash[:], hash[:]), nil, 0)
+}
+
+// GetUncheckedBlocks retrieves all unchecked blocks that are waiting for the
+// block with the given hash.
+func (t *MemoryStoreTxn) GetUncheckedBlocks(parentHash block.Hash) ([]*UncheckedBlock, error) {
+	var blocks []*UncheckedBlock
+	err := t.iterate(memoryKey(idPrefixUnchecked, parentHash[:]), func(key []byte, item *memoryItem) error {
+		var blk UncheckedBlock
+		if err := blk.UnmarshalBinary(item.value); err != nil {
+			return err
+		}
+
+		blocks = append(blocks, &blk)
+		return nil
+	})
+
+	return blocks, err
+}
+
+func (t *MemoryStoreTxn) DeleteUncheckedBlock(parentHash block.Hash, hash block.Hash) error {
+	key := memoryKey(idPrefixUnchecked, parentHash[:], hash[:])
+
+	// the timestamp is needed to remove the block from the expiry index
+	item, err := t.get(key)
+	if err != nil {
+		if err == ErrNotFound {
+			return nil
+		}
+		return err
+	}
+	var blk UncheckedBlock
+	if err := blk.UnmarshalBinary(item.value); err != nil {
+		return err
+	}
+
+	if err := t.delete(memoryKey(idPrefixUncheckedExpiry, uncheckedTimestamp(blk.Timestamp), parentHash[:], hash[:])); err != nil {
+		return err
+	}
+	return t.delete(key)
+}
+
+// WalkUncheckedBlocks calls the given function for every unchecked block in the
+// store. If fn returns an error, the walk is stopped and that error is
+// returned.
+func (t *MemoryStoreTxn) WalkUncheckedBlocks(fn func(parentHash block.Hash, blk *UncheckedBlock) error) error {
+	return t.iterate([]byte{idPrefixUnchecked}, func(key []byte, item *memoryItem) error {
+		var blk UncheckedBlock
+		if err := blk.UnmarshalBinary(item.value); err != nil {
+			return err
+		}
+
+		var parentHash block.Hash
+		copy(parentHash[:], key[1:])
+
+		return fn(parentHash, &blk)
+	})
+}
+
+// WalkExpiredUncheckedBlocks calls the given function for every unchecked block
+// in the store with a timestamp before the given time, starting with the oldest
+// one. If fn returns an error, the walk is stopped and that error is returned.
+func (t *MemoryStoreTxn) WalkExpiredUncheckedBlocks(before time.Time, fn func(parentHash block.Hash, hash block.Hash) error) error {
+	end := memoryKey(idPrefixUncheckedExpiry, uncheckedTimestamp(before))
+	return t.iterate([]byte{idPrefixUncheckedExpiry}, func(key []byte, item *memoryItem) error {
+		if bytes.Compare(key, end) >= 0 {
+			return nil
+		}
+
+		var parentHash, hash block.Hash
+		copy(parentHash[:], key[1+8:])
+		copy(hash[:], key[1+8+block.HashSize:])
+
+		return fn(parentHash, hash)
+	})
+}
+
+func (t *MemoryStoreTxn) CountUncheckedBlocks() (uint64, error) {
+	return t.count([]byte{idPrefixUnchecked})
+}
+
+// AddFork adds the given block to the list of competing blocks for the given
+// root.
+func (t *MemoryStoreTxn) AddFork(root block.Hash, blk block.Block) error {
+	hash := blk.Hash()
+	blockBytes, err := blk.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	key := memoryKey(idPrefixFork, root[:], hash[:])
+	if t.has(key) {
+		return ErrForkExists
+	}
+
+	return t.set(key, blockBytes, blk.ID())
+}
+
+// GetForks retrieves the competing blocks for the given root.
+func (t *MemoryStoreTxn) GetForks(root block.Hash) ([]block.Block, error) {
+	var blocks []block.Block
+	err := t.iterate(memoryKey(idPrefixFork, root[:]), func(key []byte, item *memoryItem) error {
+		blk, err := memoryBlock(item)
+		if err != nil {
+			return err
+		}
+
+		blocks = append(blocks, blk)
+		return nil
+	})
+
+	return blocks, err
+}
+
+// DeleteForks removes all competing blocks for the given root.
+func (t *MemoryStoreTxn) DeleteForks(root block.Hash) error {
+	return t.iterate(memoryKey(idPrefixFork, root[:]), func(key []byte, item *memoryItem) error {
+		return t.delete(key)
+	})
+}
+
+func (t *MemoryStoreTxn) SetVoteSequence(address wallet.Address, sequence uint64) error {
+	var sequenceBytes [8]byte
+	binary.LittleEndian.PutUint64(sequenceBytes[:], sequence)
+	return t.set(memoryKey(idPrefixVoteSequence, address), sequenceBytes[:], 0)
+}
+
+func (t *MemoryStoreTxn) GetVoteSequence(address wallet.Address) (uint64, error) {
+	item, err := t.get(memoryKey(idPrefixVoteSequence, address))
+	if err != nil {
+		if err == ErrNotFound {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	if len(item.value) != 8 {
+		return 0, errors.New("bad vote sequence size")
+	}
+
+	return binary.LittleEndian.Uint64(item.value), nil
+}
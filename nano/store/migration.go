@@ -0,0 +1,577 @@
+package store
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/alexbakker/gonano/nano/block"
+	"github.com/alexbakker/gonano/nano/wallet"
+	"github.com/dgraph-io/badger"
+)
+
+const (
+	metaKeyVersion byte = iota
+	metaKeyMigrationCursor
+)
+
+// badgerMigrationLimit is the maximum amount of changes a migration makes in a
+// single transaction. Badger refuses to commit transactions that grow too big.
+const badgerMigrationLimit = 10000
+
+var (
+	ErrSchemaVersion = errors.New("unsupported database schema version")
+)
+
+// badgerMigration upgrades the contents of a badger database by one schema
+// version. It makes at most limit changes in the given transaction and returns
+// a cursor to continue from in the next one, or nil once it's done. The first
+// call receives a nil cursor.
+type badgerMigration func(txn *badger.Txn, cursor []byte, limit int) ([]byte, error)
+
+// badgerMigrations contains the steps needed to upgrade a database to the
+// current schema version. The migration at index i upgrades a database from
+// version i to version i+1, so the current schema version equals the amount of
+// migrations.
+var badgerMigrations = []badgerMigration{
+	migrateBadgerUnversioned,
+	migrateBadgerBlockInfo,
+	migrateBadgerUncheckedExpiry,
+}
+
+// migrateBadgerUnversioned upgrades databases that were created before the
+// schema version was recorded. Frontiers that were left behind for blocks that
+// are no longer the head of their account are removed first. The
+// representative weights in those databases were encoded in little endian,
+// didn't include the genesis account and were lowered by the balance instead of
+// the amount of send blocks, so they are rebuilt from the balances of the
+// accounts next. The first byte of the cursor tells which of the two is in
+// progress and the rest is the key to continue from.
+func migrateBadgerUnversioned(txn *badger.Txn, cursor []byte, limit int) ([]byte, error) {
+	if len(cursor) == 0 || cursor[0] == idPrefixFrontier {
+		next, err := migrateBadgerFrontiers(txn, cursor, limit)
+		if err != nil || next != nil {
+			return next, err
+		}
+		cursor = []byte{idPrefixRepresentation}
+	}
+
+	return migrateBadgerWeights(txn, cursor, limit)
+}
+
+// migrateBadgerFrontiers removes the frontiers of blocks that are no longer the
+// head of their account.
+func migrateBadgerFrontiers(txn *badger.Txn, cursor []byte, limit int) ([]byte, error) {
+	store := &BadgerStoreTxn{txn}
+
+	var next []byte
+	count := 0
+	err := iterateBadgerFrom(txn, idPrefixFrontier, cursor, func(key []byte, value []byte) error {
+		if count >= limit {
+			next = key
+			return errBatchDone
+		}
+
+		info, err := store.GetAddress(wallet.Address(value))
+		if err != nil && err != ErrNotFound {
+			return err
+		}
+		if info != nil && bytes.Equal(info.HeadBlock[:], key[1:]) {
+			return nil
+		}
+
+		count++
+		return txn.Delete(key)
+	})
+	if err != nil && err != errBatchDone {
+		return nil, err
+	}
+
+	return next, nil
+}
+
+// migrateBadgerWeights rebuilds the representative weights from the balances of
+// the accounts. The weights are set and the stale ones are removed in order of
+// the address of the representative.
+func migrateBadgerWeights(txn *badger.Txn, cursor []byte, limit int) ([]byte, error) {
+	store := &BadgerStoreTxn{txn}
+
+	weights := make(map[string]wallet.Balance)
+	err := iterateBadger(txn, idPrefixAddress, func(key []byte, value []byte) error {
+		var info AddressInfo
+		if err := info.UnmarshalBinary(value); err != nil {
+			return err
+		}
+
+		blk, err := store.GetBlock(info.RepBlock)
+		if err != nil {
+			return err
+		}
+		rep, err := blockRepresentative(blk)
+		if err != nil {
+			return err
+		}
+
+		weight, ok := weights[string(rep)]
+		if !ok {
+			weight = wallet.ZeroBalance
+		}
+		weights[string(rep)] = weight.Add(info.Balance)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var reps []string
+	for rep := range weights {
+		reps = append(reps, rep)
+	}
+	err = iterateBadger(txn, idPrefixRepresentation, func(key []byte, value []byte) error {
+		if _, ok := weights[string(key[1:])]; !ok {
+			reps = append(reps, string(key[1:]))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(reps)
+
+	// skip the representatives that were handled in previous batches
+	start := sort.SearchStrings(reps, string(cursor[1:]))
+	for i, rep := range reps[start:] {
+		if i >= limit {
+			return append([]byte{idPrefixRepresentation}, rep...), nil
+		}
+
+		weight, ok := weights[rep]
+		if !ok {
+			err = txn.Delete(append([]byte{idPrefixRepresentation}, rep...))
+		} else {
+			err = store.setRepresentation(wallet.Address(rep), weight)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return nil, nil
+}
+
+// migrateBadgerBlockInfo stores the details of every block in the database,
+// which were derived from the account chains before. The blocks are visited in
+// order of their hash, starting at the one in the cursor, but the details of a
+// block are only derived after those of the blocks it depends on, so every
+// chain is filled in from its start.
+func migrateBadgerBlockInfo(txn *badger.Txn, cursor []byte, limit int) ([]byte, error) {
+	store := &BadgerStoreTxn{txn}
+
+	var start block.Hash
+	copy(start[:], cursor)
+
+	var next []byte
+	var supply *wallet.Balance
+	count := 0
+	err := store.WalkBlocks(start, func(blk block.Block) error {
+		hash := blk.Hash()
+		n, err := badgerBlockInfo(store, hash, limit-count, &supply)
+		if err != nil {
+			return err
+		}
+
+		// revisit the block in the next batch, in case its chain is incomplete
+		if count += n; count >= limit {
+			next = hash[:]
+			return errBatchDone
+		}
+		return nil
+	})
+	if err != nil && err != errBatchDone {
+		return nil, err
+	}
+
+	return next, nil
+}
+
+// badgerBlockInfo derives and stores the details of the block with the given
+// hash and of the blocks it depends on, if they haven't been stored yet. It
+// stops after storing the details of limit blocks and returns the amount of
+// blocks it stored the details of. The total supply is only calculated once
+// it's needed for the genesis block.
+func badgerBlockInfo(store *BadgerStoreTxn, hash block.Hash, limit int, supply **wallet.Balance) (int, error) {
+	count := 0
+	stack := []block.Hash{hash}
+	for len(stack) > 0 && count < limit {
+		hash := stack[len(stack)-1]
+		if _, err := store.GetBlockInfo(hash); err != ErrNotFound {
+			if err != nil {
+				return count, err
+			}
+			stack = stack[:len(stack)-1]
+			continue
+		}
+
+		blk, err := store.GetBlock(hash)
+		if err != nil {
+			return count, err
+		}
+
+		// the details of the dependencies have to be stored first
+		deps, err := badgerBlockDependencies(store, blk)
+		if err != nil {
+			return count, err
+		}
+		if len(deps) > 0 {
+			stack = append(stack, deps...)
+			continue
+		}
+
+		info, err := deriveBadgerBlockInfo(store, blk, supply)
+		if err != nil {
+			return count, err
+		}
+		if err = store.SetBlockInfo(hash, info); err != nil {
+			return count, err
+		}
+		stack = stack[:len(stack)-1]
+		count++
+	}
+
+	return count, nil
+}
+
+// badgerBlockDependencies returns the hashes of the blocks the details of the
+// given block are derived from that don't have their details stored yet.
+func badgerBlockDependencies(store *BadgerStoreTxn, blk block.Block) ([]block.Hash, error) {
+	hashes := []block.Hash{previous(blk)}
+	switch b := blk.(type) {
+	case *block.OpenBlock:
+		// only the genesis block opens an account without a source block
+		found, err := store.HasBlock(b.SourceHash)
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			hashes = append(hashes, b.SourceHash)
+		}
+	case *block.ReceiveBlock:
+		hashes = append(hashes, b.SourceHash)
+	}
+
+	var deps []block.Hash
+	for _, hash := range hashes {
+		if hash.IsZero() {
+			continue
+		}
+
+		if _, err := store.GetBlockInfo(hash); err != ErrNotFound {
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		deps = append(deps, hash)
+	}
+
+	return deps, nil
+}
+
+// deriveBadgerBlockInfo derives the details of the given block from the stored
+// details of the blocks it depends on.
+func deriveBadgerBlockInfo(store *BadgerStoreTxn, blk block.Block, supply **wallet.Balance) (*BlockInfo, error) {
+	var err error
+	var prev *BlockInfo
+	if prevHash := previous(blk); !prevHash.IsZero() {
+		if prev, err = store.GetBlockInfo(prevHash); err != nil {
+			return nil, err
+		}
+	}
+
+	var info BlockInfo
+	switch b := blk.(type) {
+	case *block.OpenBlock:
+		source, err := store.GetBlockInfo(b.SourceHash)
+		if err == ErrNotFound {
+			if *supply == nil {
+				if *supply, err = badgerSupply(store.txn); err != nil {
+					return nil, err
+				}
+			}
+			info = BlockInfo{Address: b.Address, Balance: **supply, Amount: **supply}
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		info = BlockInfo{Address: b.Address, Balance: source.Amount, Amount: source.Amount}
+	case *block.SendBlock:
+		info = BlockInfo{Address: prev.Address, Balance: b.Balance, Amount: prev.Balance.Sub(b.Balance)}
+	case *block.ReceiveBlock:
+		source, err := store.GetBlockInfo(b.SourceHash)
+		if err != nil {
+			return nil, err
+		}
+		info = BlockInfo{Address: prev.Address, Balance: prev.Balance.Add(source.Amount), Amount: source.Amount}
+	case *block.ChangeBlock:
+		info = BlockInfo{Address: prev.Address, Balance: prev.Balance, Amount: wallet.ZeroBalance}
+	case *block.StateBlock:
+		prevBalance := wallet.ZeroBalance
+		if prev != nil {
+			prevBalance = prev.Balance
+		}
+
+		info = BlockInfo{Address: b.Address, Balance: b.Balance}
+		if b.Balance.Compare(prevBalance) == wallet.BalanceCompSmaller {
+			info.Amount = prevBalance.Sub(b.Balance)
+		} else {
+			info.Amount = b.Balance.Sub(prevBalance)
+		}
+	default:
+		return nil, errors.New("unsupported block type")
+	}
+
+	return &info, nil
+}
+
+// badgerSupply returns the amount the genesis block received: the whole supply,
+// which is either held by the accounts or waiting to be received.
+func badgerSupply(txn *badger.Txn) (*wallet.Balance, error) {
+	supply := wallet.ZeroBalance
+	err := iterateBadger(txn, idPrefixAddress, func(key []byte, value []byte) error {
+		var info AddressInfo
+		if err := info.UnmarshalBinary(value); err != nil {
+			return err
+		}
+		supply = supply.Add(info.Balance)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = iterateBadger(txn, idPrefixPending, func(key []byte, value []byte) error {
+		var pending Pending
+		if err := pending.UnmarshalBinary(value); err != nil {
+			return err
+		}
+		supply = supply.Add(pending.Amount)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &supply, nil
+}
+
+// migrateBadgerUncheckedExpiry adds the unchecked blocks to the index that
+// sorts them by their timestamp, which was introduced to purge expired blocks
+// without walking the whole unchecked list. The cursor is the key of the
+// unchecked block to continue from.
+func migrateBadgerUncheckedExpiry(txn *badger.Txn, cursor []byte, limit int) ([]byte, error) {
+	var next []byte
+	count := 0
+	err := iterateBadgerFrom(txn, idPrefixUnchecked, cursor, func(key []byte, value []byte) error {
+		if count >= limit {
+			next = key
+			return errBatchDone
+		}
+
+		var blk UncheckedBlock
+		if err := blk.UnmarshalBinary(value); err != nil {
+			return err
+		}
+
+		var parentHash, hash block.Hash
+		copy(parentHash[:], key[1:])
+		copy(hash[:], key[1+block.HashSize:])
+
+		count++
+		return txn.Set(badgerUncheckedExpiryKey(blk.Timestamp, parentHash, hash), nil)
+	})
+	if err != nil && err != errBatchDone {
+		return nil, err
+	}
+
+	return next, nil
+}
+
+// iterateBadger calls the given function with a copy of the key and value of
+// every item with the given prefix.
+func iterateBadger(txn *badger.Txn, prefix byte, fn func(key []byte, value []byte) error) error {
+	return iterateBadgerFrom(txn, prefix, nil, fn)
+}
+
+// iterateBadgerFrom is like iterateBadger, but starts at the given key if it
+// isn't empty.
+func iterateBadgerFrom(txn *badger.Txn, prefix byte, start []byte, fn func(key []byte, value []byte) error) error {
+	it := txn.NewIterator(badger.DefaultIteratorOptions)
+	defer it.Close()
+
+	if len(start) == 0 {
+		start = []byte{prefix}
+	}
+
+	for it.Seek(start); it.ValidForPrefix([]byte{prefix}); it.Next() {
+		item := it.Item()
+		value, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+
+		if err := fn(append([]byte(nil), item.Key()...), value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// BadgerSchemaVersion returns the schema version of newly created badger
+// databases.
+func BadgerSchemaVersion() uint32 {
+	return uint32(len(badgerMigrations))
+}
+
+// checkBadgerVersion makes sure the given database has the current schema
+// version, without changing it.
+func checkBadgerVersion(db *badger.DB) error {
+	return db.View(func(txn *badger.Txn) error {
+		version, err := getBadgerVersion(txn)
+		if err == ErrNotFound {
+			if badgerEmpty(txn) {
+				return ErrStoreEmpty
+			}
+			version = 0
+		} else if err != nil {
+			return err
+		}
+
+		if version != BadgerSchemaVersion() {
+			return ErrSchemaVersion
+		}
+		return nil
+	})
+}
+
+// migrateBadger upgrades the given database to the version that follows the
+// last of the given migrations. Every step is run in batches of at most limit
+// changes. Each batch is committed together with the cursor of the step and the
+// last one together with the update of the recorded version, so an interrupted
+// upgrade can be resumed from the last completed batch.
+func migrateBadger(db *badger.DB, migrations []badgerMigration, limit int) error {
+	target := uint32(len(migrations))
+
+	var version uint32
+	err := db.Update(func(txn *badger.Txn) error {
+		var err error
+		version, err = getBadgerVersion(txn)
+		if err != ErrNotFound {
+			return err
+		}
+
+		// a database without a version is either new or predates versioning
+		if badgerEmpty(txn) {
+			version = target
+		} else {
+			version = 0
+		}
+		return setBadgerVersion(txn, version)
+	})
+	if err != nil {
+		return err
+	}
+
+	if version > target {
+		return ErrSchemaVersion
+	}
+
+	for ; version < target; version++ {
+		for done := false; !done; {
+			err := db.Update(func(txn *badger.Txn) error {
+				cursor, err := getBadgerCursor(txn)
+				if err != nil {
+					return err
+				}
+
+				cursor, err = migrations[version](txn, cursor, limit)
+				if err != nil {
+					return err
+				}
+				if cursor != nil {
+					return setBadgerCursor(txn, cursor)
+				}
+
+				done = true
+				if err := txn.Delete([]byte{idPrefixMeta, metaKeyMigrationCursor}); err != nil {
+					return err
+				}
+				return setBadgerVersion(txn, version+1)
+			})
+			if err != nil {
+				return fmt.Errorf("migration to schema version %d failed: %s", version+1, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// badgerEmpty reports whether the database contains no keys at all.
+func badgerEmpty(txn *badger.Txn) bool {
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchValues = false
+
+	it := txn.NewIterator(opts)
+	defer it.Close()
+
+	it.Rewind()
+	return !it.Valid()
+}
+
+func getBadgerVersion(txn *badger.Txn) (uint32, error) {
+	item, err := txn.Get([]byte{idPrefixMeta, metaKeyVersion})
+	if err != nil {
+		if err == badger.ErrKeyNotFound {
+			return 0, ErrNotFound
+		}
+		return 0, err
+	}
+
+	versionBytes, err := item.Value()
+	if err != nil {
+		return 0, err
+	}
+	if len(versionBytes) != 4 {
+		return 0, errors.New("bad schema version size")
+	}
+
+	return binary.BigEndian.Uint32(versionBytes), nil
+}
+
+func setBadgerVersion(txn *badger.Txn, version uint32) error {
+	var versionBytes [4]byte
+	binary.BigEndian.PutUint32(versionBytes[:], version)
+	return txn.Set([]byte{idPrefixMeta, metaKeyVersion}, versionBytes[:])
+}
+
+// getBadgerCursor returns the cursor of the migration step that is in
+// progress, or nil if there is none.
+func getBadgerCursor(txn *badger.Txn) ([]byte, error) {
+	item, err := txn.Get([]byte{idPrefixMeta, metaKeyMigrationCursor})
+	if err != nil {
+		if err == badger.ErrKeyNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return item.ValueCopy(nil)
+}
+
+func setBadgerCursor(txn *badger.Txn, cursor []byte) error {
+	return txn.Set([]byte{idPrefixMeta, metaKeyMigrationCursor}, cursor)
+}
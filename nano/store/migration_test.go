@@ -0,0 +1,531 @@
+package store
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/alexbakker/gonano/nano/block"
+	"github.com/alexbakker/gonano/nano/wallet"
+	"github.com/dgraph-io/badger"
+)
+
+func openTestBadger(t *testing.T, dir string) *badger.DB {
+	opts := badger.DefaultOptions
+	opts.Dir = dir
+	opts.ValueDir = dir
+
+	db, err := badger.Open(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func testBadgerVersion(t *testing.T, db *badger.DB) uint32 {
+	var version uint32
+	err := db.View(func(txn *badger.Txn) error {
+		var err error
+		version, err = getBadgerVersion(txn)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return version
+}
+
+func TestBadgerMigration(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gonano_test_")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// new databases start out at the current version
+	db := openTestBadger(t, dir)
+	if err = migrateBadger(db, badgerMigrations, badgerMigrationLimit); err != nil {
+		t.Fatal(err)
+	}
+	if version := testBadgerVersion(t, db); version != BadgerSchemaVersion() {
+		t.Fatalf("expected version %d, got: %d", BadgerSchemaVersion(), version)
+	}
+
+	// simulate a database that predates versioning
+	blocks := parseBlocks(t, "./testdata/blocks.json")
+	err = db.Update(func(txn *badger.Txn) error {
+		if err := (&BadgerStoreTxn{txn}).AddBlock(blocks[0]); err != nil {
+			return err
+		}
+		return txn.Delete([]byte{idPrefixMeta, metaKeyVersion})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var steps []int
+	errTest := errors.New("test error")
+	migrations := []badgerMigration{
+		func(txn *badger.Txn, cursor []byte, limit int) ([]byte, error) {
+			steps = append(steps, 0)
+			return nil, nil
+		},
+		func(txn *badger.Txn, cursor []byte, limit int) ([]byte, error) {
+			steps = append(steps, 1)
+			return nil, errTest
+		},
+		func(txn *badger.Txn, cursor []byte, limit int) ([]byte, error) {
+			steps = append(steps, 2)
+			return nil, nil
+		},
+	}
+
+	// a failed step should leave the database at the last completed version
+	if err = migrateBadger(db, migrations, badgerMigrationLimit); err == nil {
+		t.Fatalf("expected the migration to fail")
+	}
+	if version := testBadgerVersion(t, db); version != 1 {
+		t.Fatalf("expected version 1, got: %d", version)
+	}
+
+	migrations[1] = func(txn *badger.Txn, cursor []byte, limit int) ([]byte, error) {
+		steps = append(steps, 1)
+		return nil, nil
+	}
+	if err = migrateBadger(db, migrations, badgerMigrationLimit); err != nil {
+		t.Fatal(err)
+	}
+	if version := testBadgerVersion(t, db); version != 3 {
+		t.Fatalf("expected version 3, got: %d", version)
+	}
+	if len(steps) != 4 || steps[0] != 0 || steps[1] != 1 || steps[2] != 1 || steps[3] != 2 {
+		t.Fatalf("unexpected migration steps: %v", steps)
+	}
+
+	// the data should survive the migration
+	err = db.View(func(txn *badger.Txn) error {
+		has, err := (&BadgerStoreTxn{txn}).HasBlock(blocks[0].Hash())
+		if err != nil {
+			return err
+		}
+		if !has {
+			t.Fatalf("block lost during migration")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// refuse to open databases of a newer version
+	if err = migrateBadger(db, migrations[:2], badgerMigrationLimit); err != ErrSchemaVersion {
+		t.Fatalf("expected: %s, got: %v", ErrSchemaVersion, err)
+	}
+	err = db.Update(func(txn *badger.Txn) error {
+		return setBadgerVersion(txn, BadgerSchemaVersion()+1)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = db.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = NewBadgerStore(dir); err != ErrSchemaVersion {
+		t.Fatalf("expected: %s, got: %v", ErrSchemaVersion, err)
+	}
+}
+
+func TestBadgerMigrationUnversioned(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gonano_test_")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := NewBadgerStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	blocks := parseTestBlocks(t, "./testdata/chains.json")
+	ledger, err := NewLedger(store, LedgerOptions{
+		GenesisBlock:   blocks.Genesis,
+		GenesisBalance: wallet.ParseBalanceInts(0xffffffffffffffff, 0xffffffffffffffff),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = ledger.AddBlocks(blocks.Blocks[:2]); err != nil {
+		t.Fatal(err)
+	}
+
+	reps := []wallet.Address{blocks.Genesis.Address, blocks.Blocks[1].(*block.OpenBlock).Representative}
+	var weights []wallet.Balance
+	for _, rep := range reps {
+		weight, err := ledger.GetRepresentation(rep)
+		if err != nil {
+			t.Fatal(err)
+		}
+		weights = append(weights, weight)
+	}
+	if err = store.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// turn the database into one that was created before versioning: the
+	// weights are encoded in little endian, the weight of the genesis account
+	// is missing and the frontiers of old heads of the genesis account were
+	// left behind
+	db := openTestBadger(t, dir)
+	stale := []block.Hash{blocks.Genesis.Hash(), blocks.Blocks[2].Hash()}
+	err = db.Update(func(txn *badger.Txn) error {
+		if err := txn.Delete([]byte{idPrefixMeta, metaKeyVersion}); err != nil {
+			return err
+		}
+		if err := txn.Delete(append([]byte{idPrefixRepresentation}, reps[0]...)); err != nil {
+			return err
+		}
+		if err := txn.Set(append([]byte{idPrefixRepresentation}, reps[1]...), weights[1].Bytes(binary.LittleEndian)); err != nil {
+			return err
+		}
+		for _, hash := range stale {
+			if err := txn.Set(append([]byte{idPrefixFrontier}, hash[:]...), blocks.Genesis.Address); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// make a single change at a time to spread the migration over batches
+	if err = migrateBadger(db, badgerMigrations[:1], 1); err != nil {
+		t.Fatal(err)
+	}
+	if err = db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if store, err = NewBadgerStore(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	err = store.View(func(txn StoreTxn) error {
+		for i, rep := range reps {
+			weight, err := txn.GetRepresentation(rep)
+			if err != nil {
+				return err
+			}
+			if !weight.Equal(weights[i]) {
+				t.Fatalf("expected a weight of %s for %s, got: %s", weights[i], rep, weight)
+			}
+		}
+
+		for _, hash := range stale {
+			if _, err := txn.GetFrontier(hash); err != ErrNotFound {
+				t.Fatalf("stale frontier was not removed: %v", err)
+			}
+		}
+		for _, blk := range blocks.Blocks[:2] {
+			if _, err := txn.GetFrontier(blk.Hash()); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBadgerMigrationBlockInfo(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gonano_test_")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := NewBadgerStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	blocks := parseTestBlocks(t, "./testdata/chains.json")
+	ledger, err := NewLedger(store, LedgerOptions{
+		GenesisBlock:   blocks.Genesis,
+		GenesisBalance: wallet.ParseBalanceInts(0xffffffffffffffff, 0xffffffffffffffff),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = ledger.AddBlocks(blocks.Blocks); err != nil {
+		t.Fatal(err)
+	}
+	if err = ledger.AddBlocks(parseBlocks(t, "./testdata/state.json")); err != nil {
+		t.Fatal(err)
+	}
+
+	infos := make(map[block.Hash]*BlockInfo)
+	err = store.View(func(txn StoreTxn) error {
+		return txn.WalkBlocks(block.Hash{}, func(blk block.Block) error {
+			info, err := txn.GetBlockInfo(blk.Hash())
+			if err != nil {
+				return err
+			}
+			infos[blk.Hash()] = info
+			return nil
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = store.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// remove the block info to turn the database into one of schema version 1
+	db := openTestBadger(t, dir)
+	err = db.Update(func(txn *badger.Txn) error {
+		for hash := range infos {
+			if err := txn.Delete(append([]byte{idPrefixBlockInfo}, hash[:]...)); err != nil {
+				return err
+			}
+		}
+		return setBadgerVersion(txn, 1)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// migrate a few blocks at a time and interrupt the migration after the
+	// first batch, which should leave the database at the previous version with
+	// the progress of the step recorded
+	const limit = 3
+	if len(infos) <= 2*limit {
+		t.Fatalf("expected more than %d blocks, got: %d", 2*limit, len(infos))
+	}
+
+	var batches int
+	errTest := errors.New("test error")
+	migrations := append([]badgerMigration(nil), badgerMigrations...)
+	migrations[1] = func(txn *badger.Txn, cursor []byte, limit int) ([]byte, error) {
+		if batches++; batches == 2 {
+			return nil, errTest
+		}
+		return migrateBadgerBlockInfo(txn, cursor, limit)
+	}
+
+	db = openTestBadger(t, dir)
+	if err = migrateBadger(db, migrations, limit); err == nil {
+		t.Fatalf("expected the migration to fail")
+	}
+	if version := testBadgerVersion(t, db); version != 1 {
+		t.Fatalf("expected version 1, got: %d", version)
+	}
+
+	var stored int
+	err = db.View(func(txn *badger.Txn) error {
+		cursor, err := getBadgerCursor(txn)
+		if err != nil {
+			return err
+		}
+		if cursor == nil {
+			t.Fatalf("expected a migration cursor")
+		}
+		return iterateBadger(txn, idPrefixBlockInfo, func(key []byte, value []byte) error {
+			stored++
+			return nil
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored != limit {
+		t.Fatalf("expected the details of %d blocks, got: %d", limit, stored)
+	}
+
+	if err = migrateBadger(db, badgerMigrations, limit); err != nil {
+		t.Fatal(err)
+	}
+	if version := testBadgerVersion(t, db); version != BadgerSchemaVersion() {
+		t.Fatalf("expected version %d, got: %d", BadgerSchemaVersion(), version)
+	}
+	err = db.View(func(txn *badger.Txn) error {
+		if cursor, err := getBadgerCursor(txn); err != nil || cursor != nil {
+			t.Fatalf("migration cursor was not removed: %x %v", cursor, err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if store, err = NewBadgerStore(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	err = store.View(func(txn StoreTxn) error {
+		for hash, expected := range infos {
+			info, err := txn.GetBlockInfo(hash)
+			if err != nil {
+				return err
+			}
+			if !bytes.Equal(info.Address, expected.Address) || !info.Balance.Equal(expected.Balance) || !info.Amount.Equal(expected.Amount) {
+				t.Fatalf("unexpected block info for %s: %s %s %s", hash, info.Address, info.Balance, info.Amount)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBadgerMigrationUncheckedExpiry(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gonano_test_")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	blocks := parseBlocks(t, "./testdata/blocks.json")
+	parentHash := blocks[0].Hash()
+	now := time.Now()
+
+	// simulate a database of schema version 2, which has unchecked blocks that
+	// are missing from the expiry index
+	db := openTestBadger(t, dir)
+	if err = migrateBadger(db, badgerMigrations, badgerMigrationLimit); err != nil {
+		t.Fatal(err)
+	}
+	err = db.Update(func(txn *badger.Txn) error {
+		for i, blk := range blocks[1:] {
+			timestamp := now.Add(time.Duration(i) * time.Second)
+			unchecked := UncheckedBlock{Block: blk, Timestamp: timestamp}
+			if err := (&BadgerStoreTxn{txn}).AddUncheckedBlock(parentHash, &unchecked); err != nil {
+				return err
+			}
+			if err := txn.Delete(badgerUncheckedExpiryKey(timestamp, parentHash, blk.Hash())); err != nil {
+				return err
+			}
+		}
+		return setBadgerVersion(txn, 2)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// add one block to the index at a time
+	if err = migrateBadger(db, badgerMigrations, 1); err != nil {
+		t.Fatal(err)
+	}
+	if version := testBadgerVersion(t, db); version != BadgerSchemaVersion() {
+		t.Fatalf("expected version %d, got: %d", BadgerSchemaVersion(), version)
+	}
+
+	err = db.View(func(txn *badger.Txn) error {
+		var expired []block.Hash
+		err := (&BadgerStoreTxn{txn}).WalkExpiredUncheckedBlocks(now.Add(time.Hour), func(parentHash block.Hash, hash block.Hash) error {
+			expired = append(expired, hash)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		if len(expired) != len(blocks)-1 {
+			t.Fatalf("expected %d expired blocks, got: %d", len(blocks)-1, len(expired))
+		}
+		for i, hash := range expired {
+			if hash != blocks[i+1].Hash() {
+				t.Fatalf("unexpected expired block %d: %s", i, hash)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = db.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOpenBadgerStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gonano_test_")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if _, err = OpenBadgerStore(dir + "/missing"); !os.IsNotExist(err) {
+		t.Fatalf("expected a not exist error, got: %v", err)
+	}
+	if _, err = os.Stat(dir + "/missing"); !os.IsNotExist(err) {
+		t.Fatalf("missing database directory was created")
+	}
+
+	// empty databases are not initialized
+	if _, err = OpenBadgerStore(dir); err != ErrStoreEmpty {
+		t.Fatalf("expected: %s, got: %v", ErrStoreEmpty, err)
+	}
+
+	store, err := NewBadgerStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = store.Update(func(txn StoreTxn) error {
+		return txn.AddFrontier(&block.Frontier{
+			Address: make(wallet.Address, wallet.AddressSize),
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = store.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if store, err = OpenBadgerStore(dir); err != nil {
+		t.Fatal(err)
+	}
+	if err = store.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// databases of another version are not migrated
+	db := openTestBadger(t, dir)
+	err = db.Update(func(txn *badger.Txn) error {
+		return setBadgerVersion(txn, BadgerSchemaVersion()-1)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = db.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = OpenBadgerStore(dir); err != ErrSchemaVersion {
+		t.Fatalf("expected: %s, got: %v", ErrSchemaVersion, err)
+	}
+
+	db = openTestBadger(t, dir)
+	if version := testBadgerVersion(t, db); version != BadgerSchemaVersion()-1 {
+		t.Fatalf("database was migrated to version %d", version)
+	}
+	if err = db.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -20,6 +20,13 @@ type Pending struct {
 	Amount  wallet.Balance
 }
 
+// PendingBlock represents a send block that is waiting to be received, together
+// with the source account and the amount that was sent.
+type PendingBlock struct {
+	Hash block.Hash
+	Pending
+}
+
 // MarshalBinary implements the encoding.BinaryMarshaler interface.
 func (p *Pending) MarshalBinary() ([]byte, error) {
 	buf := new(bytes.Buffer)
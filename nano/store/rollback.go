@@ -0,0 +1,381 @@
+package store
+
+import (
+	"errors"
+
+	"github.com/alexbakker/gonano/nano/block"
+	"github.com/alexbakker/gonano/nano/wallet"
+)
+
+var (
+	ErrRollbackGenesis = errors.New("the genesis block can't be rolled back")
+)
+
+// Rollback removes the block with the given hash from the ledger, together with
+// all blocks that follow it in the account chain. The effects of those blocks
+// on the balance and representative of the account, the pending transactions
+// and the voting weight of representatives are undone. If one of the removed
+// send blocks was already received, the receiving account is rolled back as
+// well.
+func (l *Ledger) Rollback(hash block.Hash) error {
+	return l.db.Update(func(txn StoreTxn) error {
+		return l.rollback(txn, hash)
+	})
+}
+
+func (l *Ledger) rollback(txn StoreTxn, hash block.Hash) error {
+	if hash.Equal(l.opts.GenesisBlock.Hash()) {
+		return ErrRollbackGenesis
+	}
+
+	address, err := l.blockAccount(txn, hash)
+	if err != nil {
+		return err
+	}
+
+	// walk back from the head block of the account until the given block has
+	// been rolled back
+	for {
+		info, err := txn.GetAddress(address)
+		if err != nil {
+			return err
+		}
+
+		head := info.HeadBlock
+		blk, err := txn.GetBlock(head)
+		if err != nil {
+			return err
+		}
+
+		if err := l.rollbackBlock(txn, address, info, blk); err != nil {
+			return err
+		}
+
+		if head.Equal(hash) {
+			return nil
+		}
+	}
+}
+
+func (l *Ledger) rollbackBlock(txn StoreTxn, address wallet.Address, info *AddressInfo, blk block.Block) error {
+	switch b := blk.(type) {
+	case *block.OpenBlock:
+		return l.rollbackOpenBlock(txn, info, b)
+	case *block.SendBlock:
+		return l.rollbackSendBlock(txn, address, info, b)
+	case *block.ReceiveBlock:
+		return l.rollbackReceiveBlock(txn, address, info, b)
+	case *block.ChangeBlock:
+		return l.rollbackChangeBlock(txn, address, info, b)
+	case *block.StateBlock:
+		return l.rollbackStateBlock(txn, info, b)
+	default:
+		panic("bad block type")
+	}
+}
+
+func (l *Ledger) rollbackOpenBlock(txn StoreTxn, info *AddressInfo, blk *block.OpenBlock) error {
+	hash := blk.Hash()
+
+	// put the received amount back on the pending transaction list
+	source, err := l.blockAccount(txn, blk.SourceHash)
+	if err != nil {
+		return err
+	}
+	pending := Pending{
+		Address: source,
+		Amount:  info.Balance,
+	}
+	if err := txn.AddPending(blk.Address, blk.SourceHash, &pending); err != nil {
+		return err
+	}
+
+	// update representative voting weight
+	if err := txn.SubRepresentation(blk.Representative, info.Balance); err != nil {
+		return err
+	}
+
+	// the account no longer exists after its open block is removed
+	if err := txn.DeleteAddress(blk.Address); err != nil {
+		return err
+	}
+	if err := txn.DeleteFrontier(hash); err != nil {
+		return err
+	}
+
+	return deleteBlock(txn, hash)
+}
+
+func (l *Ledger) rollbackSendBlock(txn StoreTxn, address wallet.Address, info *AddressInfo, blk *block.SendBlock) error {
+	hash := blk.Hash()
+
+	// if the destination has already received this block, roll that back first
+	pending, err := txn.GetPending(blk.Destination, hash)
+	if err != nil {
+		receiver, err := l.receiver(txn, blk.Destination, hash)
+		if err != nil {
+			return err
+		}
+		if err := l.rollback(txn, receiver); err != nil {
+			return err
+		}
+
+		if pending, err = txn.GetPending(blk.Destination, hash); err != nil {
+			return err
+		}
+	}
+
+	// remove the pending transaction
+	if err := txn.DeletePending(blk.Destination, hash); err != nil {
+		return err
+	}
+
+	// update the address info
+	info.HeadBlock = blk.PreviousHash
+	info.Balance = info.Balance.Add(pending.Amount)
+	if err := txn.UpdateAddress(address, info); err != nil {
+		return err
+	}
+
+	// update representative voting weight
+	rep, err := l.getRepresentative(txn, address)
+	if err != nil {
+		return err
+	}
+	if err := txn.AddRepresentation(rep, pending.Amount); err != nil {
+		return err
+	}
+
+	return l.rollbackHead(txn, address, hash, blk.PreviousHash)
+}
+
+func (l *Ledger) rollbackReceiveBlock(txn StoreTxn, address wallet.Address, info *AddressInfo, blk *block.ReceiveBlock) error {
+	hash := blk.Hash()
+
+	// calculate the received amount
+	balance, err := l.balance(txn, blk.PreviousHash)
+	if err != nil {
+		return err
+	}
+	amount := info.Balance.Sub(balance)
+
+	// put the received amount back on the pending transaction list
+	source, err := l.blockAccount(txn, blk.SourceHash)
+	if err != nil {
+		return err
+	}
+	pending := Pending{
+		Address: source,
+		Amount:  amount,
+	}
+	if err := txn.AddPending(address, blk.SourceHash, &pending); err != nil {
+		return err
+	}
+
+	// update the address info
+	info.HeadBlock = blk.PreviousHash
+	info.Balance = balance
+	if err := txn.UpdateAddress(address, info); err != nil {
+		return err
+	}
+
+	// update representative voting weight
+	rep, err := l.getRepresentative(txn, address)
+	if err != nil {
+		return err
+	}
+	if err := txn.SubRepresentation(rep, amount); err != nil {
+		return err
+	}
+
+	return l.rollbackHead(txn, address, hash, blk.PreviousHash)
+}
+
+func (l *Ledger) rollbackChangeBlock(txn StoreTxn, address wallet.Address, info *AddressInfo, blk *block.ChangeBlock) error {
+	hash := blk.Hash()
+
+	// find the block that set the previous representative
+	repBlock, err := l.repBlock(txn, blk.PreviousHash)
+	if err != nil {
+		return err
+	}
+
+	// update the address info
+	info.HeadBlock = blk.PreviousHash
+	info.RepBlock = repBlock
+	if err := txn.UpdateAddress(address, info); err != nil {
+		return err
+	}
+
+	// move the voting weight back to the previous representative
+	rep, err := l.getRepresentative(txn, address)
+	if err != nil {
+		return err
+	}
+	if err := txn.SubRepresentation(blk.Representative, info.Balance); err != nil {
+		return err
+	}
+	if err := txn.AddRepresentation(rep, info.Balance); err != nil {
+		return err
+	}
+
+	return l.rollbackHead(txn, address, hash, blk.PreviousHash)
+}
+
+func (l *Ledger) rollbackStateBlock(txn StoreTxn, info *AddressInfo, blk *block.StateBlock) error {
+	hash := blk.Hash()
+
+	// obtain the balance as of the previous block to find out what kind of
+	// block this is
+	oldBalance := wallet.ZeroBalance
+	if !blk.IsOpen() {
+		var err error
+		if oldBalance, err = l.balance(txn, blk.PreviousHash); err != nil {
+			return err
+		}
+	}
+
+	switch blk.Balance.Compare(oldBalance) {
+	case wallet.BalanceCompSmaller:
+		// if the destination has already received this block, roll that back
+		// first
+		destination := wallet.Address(blk.Link[:])
+		if _, err := txn.GetPending(destination, hash); err != nil {
+			receiver, err := l.receiver(txn, destination, hash)
+			if err != nil {
+				return err
+			}
+			if err := l.rollback(txn, receiver); err != nil {
+				return err
+			}
+		}
+
+		// remove the pending transaction
+		if err := txn.DeletePending(destination, hash); err != nil {
+			return err
+		}
+	case wallet.BalanceCompBigger:
+		// put the received amount back on the pending transaction list
+		source, err := l.blockAccount(txn, blk.Link)
+		if err != nil {
+			return err
+		}
+		pending := Pending{
+			Address: source,
+			Amount:  blk.Balance.Sub(oldBalance),
+		}
+		if err := txn.AddPending(blk.Address, blk.Link, &pending); err != nil {
+			return err
+		}
+	}
+
+	// update representative voting weight
+	if err := txn.SubRepresentation(blk.Representative, blk.Balance); err != nil {
+		return err
+	}
+
+	// the account no longer exists after its open block is removed
+	if blk.IsOpen() {
+		if err := txn.DeleteAddress(blk.Address); err != nil {
+			return err
+		}
+		if err := txn.DeleteFrontier(hash); err != nil {
+			return err
+		}
+		return deleteBlock(txn, hash)
+	}
+
+	// find the block that set the previous representative
+	repBlock, err := l.repBlock(txn, blk.PreviousHash)
+	if err != nil {
+		return err
+	}
+
+	// update the address info
+	info.HeadBlock = blk.PreviousHash
+	info.RepBlock = repBlock
+	info.Balance = oldBalance
+	if err := txn.UpdateAddress(blk.Address, info); err != nil {
+		return err
+	}
+
+	// move the voting weight back to the previous representative
+	rep, err := l.getRepresentative(txn, blk.Address)
+	if err != nil {
+		return err
+	}
+	if err := txn.AddRepresentation(rep, oldBalance); err != nil {
+		return err
+	}
+
+	return l.rollbackHead(txn, blk.Address, hash, blk.PreviousHash)
+}
+
+// rollbackHead removes the head block with the given hash from the store and
+// makes the given previous block the frontier of the account.
+func (l *Ledger) rollbackHead(txn StoreTxn, address wallet.Address, hash block.Hash, previous block.Hash) error {
+	if err := txn.DeleteFrontier(hash); err != nil {
+		return err
+	}
+
+	frontier := block.Frontier{
+		Address: address,
+		Hash:    previous,
+	}
+	if err := txn.AddFrontier(&frontier); err != nil {
+		return err
+	}
+
+	return deleteBlock(txn, hash)
+}
+
+// receiver returns the hash of the block in the chain of the given account that
+// received the send block with the given hash.
+func (l *Ledger) receiver(txn StoreTxn, address wallet.Address, source block.Hash) (block.Hash, error) {
+	info, err := txn.GetAddress(address)
+	if err != nil {
+		return block.Hash{}, err
+	}
+
+	for hash := info.HeadBlock; !hash.IsZero(); {
+		blk, err := txn.GetBlock(hash)
+		if err != nil {
+			return block.Hash{}, err
+		}
+
+		switch b := blk.(type) {
+		case *block.OpenBlock:
+			if b.SourceHash.Equal(source) {
+				return hash, nil
+			}
+		case *block.ReceiveBlock:
+			if b.SourceHash.Equal(source) {
+				return hash, nil
+			}
+		case *block.StateBlock:
+			if b.Link.Equal(source) {
+				return hash, nil
+			}
+		}
+		hash = previous(blk)
+	}
+
+	return block.Hash{}, errors.New("send block was not received")
+}
+
+// repBlock returns the hash of the block that set the representative of the
+// account as of the block with the given hash.
+func (l *Ledger) repBlock(txn StoreTxn, hash block.Hash) (block.Hash, error) {
+	for {
+		blk, err := txn.GetBlock(hash)
+		if err != nil {
+			return block.Hash{}, err
+		}
+
+		switch blk.(type) {
+		case *block.OpenBlock, *block.ChangeBlock, *block.StateBlock:
+			return hash, nil
+		}
+		hash = previous(blk)
+	}
+}
@@ -2,17 +2,31 @@ package store
 
 import (
 	"errors"
+	"time"
 
 	"github.com/alexbakker/gonano/nano/block"
 	"github.com/alexbakker/gonano/nano/wallet"
 )
 
 var (
-	ErrBlockExists = errors.New("block already exists")
-	ErrStoreEmpty  = errors.New("the store is empty")
+	ErrBlockExists     = errors.New("block already exists")
+	ErrAddressExists   = errors.New("address already exists")
+	ErrFrontierExists  = errors.New("frontier already exists")
+	ErrPendingExists   = errors.New("pending transaction already exists")
+	ErrUncheckedExists = errors.New("unchecked block already exists")
+	ErrForkExists      = errors.New("fork already exists")
+	ErrStoreEmpty      = errors.New("the store is empty")
+	ErrNotFound        = errors.New("key not found")
+
+	// errBatchDone is returned by walk functions to stop the walk once a batch
+	// is complete.
+	errBatchDone = errors.New("batch done")
 )
 
 // Store is an interface that all Nano block lattice stores need to implement.
+// Changes made in an Update transaction are only committed if fn returns nil.
+// Transactions started with View are read-only. Lookups of keys that don't
+// exist return ErrNotFound.
 type Store interface {
 	Close() error
 	Purge() error
@@ -26,11 +40,16 @@ type StoreTxn interface {
 	GetBlock(hash block.Hash) (block.Block, error)
 	DeleteBlock(hash block.Hash) error
 	HasBlock(hash block.Hash) (bool, error)
+	WalkBlocks(start block.Hash, fn func(blk block.Block) error) error
 	CountBlocks() (uint64, error)
+	SetBlockInfo(hash block.Hash, info *BlockInfo) error
+	GetBlockInfo(hash block.Hash) (*BlockInfo, error)
+	DeleteBlockInfo(hash block.Hash) error
 	AddAddress(address wallet.Address, info *AddressInfo) error
 	GetAddress(address wallet.Address) (*AddressInfo, error)
 	UpdateAddress(address wallet.Address, info *AddressInfo) error
 	DeleteAddress(address wallet.Address) error
+	WalkAddresses(start wallet.Address, fn func(address wallet.Address, info *AddressInfo) error) error
 	AddFrontier(frontier *block.Frontier) error
 	GetFrontier(hash block.Hash) (*block.Frontier, error)
 	GetFrontiers() ([]*block.Frontier, error)
@@ -38,8 +57,20 @@ type StoreTxn interface {
 	CountFrontiers() (uint64, error)
 	AddPending(destination wallet.Address, hash block.Hash, pending *Pending) error
 	GetPending(destination wallet.Address, hash block.Hash) (*Pending, error)
+	GetPendings(destination wallet.Address) ([]*PendingBlock, error)
 	DeletePending(destination wallet.Address, hash block.Hash) error
 	AddRepresentation(address wallet.Address, amount wallet.Balance) error
 	SubRepresentation(address wallet.Address, amount wallet.Balance) error
 	GetRepresentation(address wallet.Address) (wallet.Balance, error)
+	AddUncheckedBlock(parentHash block.Hash, blk *UncheckedBlock) error
+	GetUncheckedBlocks(parentHash block.Hash) ([]*UncheckedBlock, error)
+	DeleteUncheckedBlock(parentHash block.Hash, hash block.Hash) error
+	WalkUncheckedBlocks(fn func(parentHash block.Hash, blk *UncheckedBlock) error) error
+	WalkExpiredUncheckedBlocks(before time.Time, fn func(parentHash block.Hash, hash block.Hash) error) error
+	CountUncheckedBlocks() (uint64, error)
+	AddFork(root block.Hash, blk block.Block) error
+	GetForks(root block.Hash) ([]block.Block, error)
+	DeleteForks(root block.Hash) error
+	SetVoteSequence(address wallet.Address, sequence uint64) error
+	GetVoteSequence(address wallet.Address) (uint64, error)
 }
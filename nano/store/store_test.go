@@ -0,0 +1,502 @@
+package store
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/alexbakker/gonano/nano/block"
+	"github.com/alexbakker/gonano/nano/wallet"
+)
+
+// testStores contains a constructor for every Store implementation. The
+// returned function closes the store and removes any files it left behind.
+var testStores = map[string]func(t *testing.T) (Store, func()){
+	"badger": func(t *testing.T) (Store, func()) {
+		dir, err := ioutil.TempDir("", "gonano_test_")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		store, err := NewBadgerStore(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return store, func() {
+			if err := store.Close(); err != nil {
+				t.Error(err)
+			}
+			if err := os.RemoveAll(dir); err != nil {
+				t.Fatal(err)
+			}
+		}
+	},
+	"memory": func(t *testing.T) (Store, func()) {
+		store := NewMemoryStore()
+		return store, func() {
+			if err := store.Close(); err != nil {
+				t.Error(err)
+			}
+		}
+	},
+}
+
+// testStore runs the given test function against every Store implementation.
+func testStore(t *testing.T, fn func(t *testing.T, store Store)) {
+	for name, newStore := range testStores {
+		t.Run(name, func(t *testing.T) {
+			store, close := newStore(t)
+			defer close()
+			fn(t, store)
+		})
+	}
+}
+
+func TestStoreBlocks(t *testing.T) {
+	blocks := parseBlocks(t, "./testdata/blocks.json")
+	info := BlockInfo{
+		Address: blocks[0].(*block.SendBlock).Destination,
+		Balance: wallet.ParseBalanceInts(0, 1000),
+		Amount:  wallet.ParseBalanceInts(0, 10),
+	}
+
+	testStore(t, func(t *testing.T, store Store) {
+		err := store.Update(func(txn StoreTxn) error {
+			if empty, err := txn.Empty(); err != nil || !empty {
+				t.Fatalf("expected an empty store, got: %t, %v", empty, err)
+			}
+
+			for _, blk := range blocks {
+				if err := txn.AddBlock(blk); err != nil {
+					return err
+				}
+			}
+			if err := txn.AddBlock(blocks[0]); err != ErrBlockExists {
+				t.Fatalf("expected: %s, got: %v", ErrBlockExists, err)
+			}
+			if err := txn.SetBlockInfo(blocks[0].Hash(), &info); err != nil {
+				return err
+			}
+
+			// changes should be visible within the same transaction
+			count, err := txn.CountBlocks()
+			if err != nil {
+				return err
+			}
+			if count != uint64(len(blocks)) {
+				t.Fatalf("expected %d blocks, got: %d", len(blocks), count)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = store.View(func(txn StoreTxn) error {
+			if empty, err := txn.Empty(); err != nil || empty {
+				t.Fatalf("expected a non-empty store, got: %t, %v", empty, err)
+			}
+
+			for _, blk := range blocks {
+				res, err := txn.GetBlock(blk.Hash())
+				if err != nil {
+					return err
+				}
+				if res.Hash() != blk.Hash() || res.ID() != blk.ID() {
+					t.Fatalf("unexpected block: %s", res.Hash())
+				}
+			}
+
+			var prev *block.Hash
+			var count int
+			err := txn.WalkBlocks(block.Hash{}, func(blk block.Block) error {
+				hash := blk.Hash()
+				if prev != nil && bytes.Compare(prev[:], hash[:]) >= 0 {
+					t.Fatalf("blocks not walked in order of their hash")
+				}
+				prev = &hash
+				count++
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			if count != len(blocks) {
+				t.Fatalf("expected to walk %d blocks, got: %d", len(blocks), count)
+			}
+
+			// walking from the last hash should only return the last block
+			var last []block.Block
+			err = txn.WalkBlocks(*prev, func(blk block.Block) error {
+				last = append(last, blk)
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			if len(last) != 1 || last[0].Hash() != *prev {
+				t.Fatalf("unexpected blocks when walking from %s", *prev)
+			}
+
+			res, err := txn.GetBlockInfo(blocks[0].Hash())
+			if err != nil {
+				return err
+			}
+			if !bytes.Equal(res.Address, info.Address) || !res.Balance.Equal(info.Balance) || !res.Amount.Equal(info.Amount) {
+				t.Fatalf("unexpected block info")
+			}
+
+			_, err = txn.GetBlock(block.Hash{})
+			if err != ErrNotFound {
+				t.Fatalf("expected: %s, got: %v", ErrNotFound, err)
+			}
+			if _, err = txn.GetBlockInfo(block.Hash{}); err != ErrNotFound {
+				t.Fatalf("expected: %s, got: %v", ErrNotFound, err)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = store.Update(func(txn StoreTxn) error {
+			hash := blocks[0].Hash()
+			if err := txn.DeleteBlock(hash); err != nil {
+				return err
+			}
+			if err := txn.DeleteBlockInfo(hash); err != nil {
+				return err
+			}
+
+			has, err := txn.HasBlock(hash)
+			if err != nil {
+				return err
+			}
+			if has {
+				t.Fatalf("block was not deleted")
+			}
+			if _, err := txn.GetBlockInfo(hash); err != ErrNotFound {
+				t.Fatalf("block info was not deleted: %v", err)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func TestStoreAccounts(t *testing.T) {
+	blocks := parseBlocks(t, "./testdata/blocks.json")
+	address := blocks[0].(*block.SendBlock).Destination
+	info := AddressInfo{
+		HeadBlock: blocks[1].Hash(),
+		OpenBlock: blocks[0].Hash(),
+		RepBlock:  blocks[0].Hash(),
+		Balance:   wallet.ParseBalanceInts(0, 1000),
+	}
+	pending := Pending{Address: address, Amount: wallet.ParseBalanceInts(0, 10)}
+
+	testStore(t, func(t *testing.T, store Store) {
+		err := store.Update(func(txn StoreTxn) error {
+			if err := txn.AddAddress(address, &info); err != nil {
+				return err
+			}
+			if err := txn.AddAddress(address, &info); err != ErrAddressExists {
+				t.Fatalf("expected: %s, got: %v", ErrAddressExists, err)
+			}
+
+			frontier := block.Frontier{Address: address, Hash: info.HeadBlock}
+			if err := txn.AddFrontier(&frontier); err != nil {
+				return err
+			}
+			if err := txn.AddFrontier(&frontier); err != ErrFrontierExists {
+				t.Fatalf("expected: %s, got: %v", ErrFrontierExists, err)
+			}
+
+			if err := txn.AddPending(address, info.HeadBlock, &pending); err != nil {
+				return err
+			}
+			if err := txn.AddPending(address, info.HeadBlock, &pending); err != ErrPendingExists {
+				t.Fatalf("expected: %s, got: %v", ErrPendingExists, err)
+			}
+			// pending transactions of other destinations shouldn't show up
+			if err := txn.AddPending(info.OpenBlock[:], info.HeadBlock, &pending); err != nil {
+				return err
+			}
+
+			if err := txn.AddRepresentation(address, wallet.ParseBalanceInts(0, 100)); err != nil {
+				return err
+			}
+			if err := txn.SubRepresentation(address, wallet.ParseBalanceInts(0, 40)); err != nil {
+				return err
+			}
+
+			return txn.SetVoteSequence(address, 42)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = store.View(func(txn StoreTxn) error {
+			res, err := txn.GetAddress(address)
+			if err != nil {
+				return err
+			}
+			if *res != info {
+				t.Fatalf("unexpected address info")
+			}
+
+			var addresses []wallet.Address
+			err = txn.WalkAddresses(address, func(walked wallet.Address, walkedInfo *AddressInfo) error {
+				if *walkedInfo != info {
+					t.Fatalf("unexpected address info")
+				}
+				addresses = append(addresses, walked)
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			if len(addresses) != 1 || !bytes.Equal(addresses[0], address) {
+				t.Fatalf("unexpected addresses: %v", addresses)
+			}
+
+			frontiers, err := txn.GetFrontiers()
+			if err != nil {
+				return err
+			}
+			if len(frontiers) != 1 || frontiers[0].Hash != info.HeadBlock || !bytes.Equal(frontiers[0].Address, address) {
+				t.Fatalf("unexpected frontiers")
+			}
+
+			resPending, err := txn.GetPending(address, info.HeadBlock)
+			if err != nil {
+				return err
+			}
+			if !bytes.Equal(resPending.Address, pending.Address) || !resPending.Amount.Equal(pending.Amount) {
+				t.Fatalf("unexpected pending transaction")
+			}
+
+			pendings, err := txn.GetPendings(address)
+			if err != nil {
+				return err
+			}
+			if len(pendings) != 1 || pendings[0].Hash != info.HeadBlock || !pendings[0].Amount.Equal(pending.Amount) {
+				t.Fatalf("unexpected pending transactions")
+			}
+
+			rep, err := txn.GetRepresentation(address)
+			if err != nil {
+				return err
+			}
+			if !rep.Equal(wallet.ParseBalanceInts(0, 60)) {
+				t.Fatalf("expected a representation of 60 raw, got: %s", rep.UnitString("raw", 0))
+			}
+
+			sequence, err := txn.GetVoteSequence(address)
+			if err != nil {
+				return err
+			}
+			if sequence != 42 {
+				t.Fatalf("expected vote sequence 42, got: %d", sequence)
+			}
+
+			// missing keys
+			if _, err := txn.GetAddress(make(wallet.Address, wallet.AddressSize)); err != ErrNotFound {
+				t.Fatalf("expected: %s, got: %v", ErrNotFound, err)
+			}
+			if _, err := txn.GetFrontier(block.Hash{}); err != ErrNotFound {
+				t.Fatalf("expected: %s, got: %v", ErrNotFound, err)
+			}
+			if _, err := txn.GetPending(address, block.Hash{}); err != ErrNotFound {
+				t.Fatalf("expected: %s, got: %v", ErrNotFound, err)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = store.Update(func(txn StoreTxn) error {
+			if err := txn.DeleteAddress(address); err != nil {
+				return err
+			}
+			if err := txn.DeleteFrontier(info.HeadBlock); err != nil {
+				return err
+			}
+			if err := txn.DeletePending(address, info.HeadBlock); err != nil {
+				return err
+			}
+
+			count, err := txn.CountFrontiers()
+			if err != nil {
+				return err
+			}
+			if count != 0 {
+				t.Fatalf("expected 0 frontiers, got: %d", count)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func TestStoreUncheckedAndForks(t *testing.T) {
+	blocks := parseBlocks(t, "./testdata/blocks.json")
+	root := blocks[0].Root()
+
+	now := time.Now()
+	testStore(t, func(t *testing.T, store Store) {
+		err := store.Update(func(txn StoreTxn) error {
+			for i, blk := range blocks[1:] {
+				unchecked := UncheckedBlock{Block: blk, Timestamp: now.Add(time.Duration(i) * time.Second)}
+				if err := txn.AddUncheckedBlock(blocks[0].Hash(), &unchecked); err != nil {
+					return err
+				}
+				if err := txn.AddFork(root, blk); err != nil {
+					return err
+				}
+			}
+
+			unchecked := UncheckedBlock{Block: blocks[1], Timestamp: time.Now()}
+			if err := txn.AddUncheckedBlock(blocks[0].Hash(), &unchecked); err != ErrUncheckedExists {
+				t.Fatalf("expected: %s, got: %v", ErrUncheckedExists, err)
+			}
+			if err := txn.AddFork(root, blocks[1]); err != ErrForkExists {
+				t.Fatalf("expected: %s, got: %v", ErrForkExists, err)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = store.Update(func(txn StoreTxn) error {
+			unchecked, err := txn.GetUncheckedBlocks(blocks[0].Hash())
+			if err != nil {
+				return err
+			}
+			if len(unchecked) != len(blocks)-1 {
+				t.Fatalf("expected %d unchecked blocks, got: %d", len(blocks)-1, len(unchecked))
+			}
+
+			// only the blocks that are older than the given time are expired
+			var expired []block.Hash
+			err = txn.WalkExpiredUncheckedBlocks(now.Add(time.Second), func(parentHash block.Hash, hash block.Hash) error {
+				if parentHash != blocks[0].Hash() {
+					t.Fatalf("unexpected parent hash: %s", parentHash)
+				}
+				expired = append(expired, hash)
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			if len(expired) != 1 || expired[0] != blocks[1].Hash() {
+				t.Fatalf("unexpected expired blocks: %v", expired)
+			}
+
+			var walked int
+			err = txn.WalkUncheckedBlocks(func(parentHash block.Hash, blk *UncheckedBlock) error {
+				if parentHash != blocks[0].Hash() {
+					t.Fatalf("unexpected parent hash: %s", parentHash)
+				}
+				walked++
+				return txn.DeleteUncheckedBlock(parentHash, blk.Block.Hash())
+			})
+			if err != nil {
+				return err
+			}
+			if walked != len(unchecked) {
+				t.Fatalf("expected to walk %d unchecked blocks, got: %d", len(unchecked), walked)
+			}
+
+			forks, err := txn.GetForks(root)
+			if err != nil {
+				return err
+			}
+			if len(forks) != len(blocks)-1 {
+				t.Fatalf("expected %d forks, got: %d", len(blocks)-1, len(forks))
+			}
+			return txn.DeleteForks(root)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = store.View(func(txn StoreTxn) error {
+			count, err := txn.CountUncheckedBlocks()
+			if err != nil {
+				return err
+			}
+			if count != 0 {
+				t.Fatalf("expected 0 unchecked blocks, got: %d", count)
+			}
+
+			// deleted blocks should be removed from the expiry index as well
+			err = txn.WalkExpiredUncheckedBlocks(now.Add(time.Hour), func(parentHash block.Hash, hash block.Hash) error {
+				t.Fatalf("unexpected expired block: %s", hash)
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+
+			forks, err := txn.GetForks(root)
+			if err != nil {
+				return err
+			}
+			if len(forks) != 0 {
+				t.Fatalf("expected 0 forks, got: %d", len(forks))
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func TestStoreTxn(t *testing.T) {
+	blocks := parseBlocks(t, "./testdata/blocks.json")
+	errTest := errors.New("test error")
+
+	testStore(t, func(t *testing.T, store Store) {
+		// changes should be rolled back if the transaction fails
+		err := store.Update(func(txn StoreTxn) error {
+			if err := txn.AddBlock(blocks[0]); err != nil {
+				return err
+			}
+			return errTest
+		})
+		if err != errTest {
+			t.Fatalf("expected: %s, got: %v", errTest, err)
+		}
+
+		err = store.View(func(txn StoreTxn) error {
+			has, err := txn.HasBlock(blocks[0].Hash())
+			if err != nil {
+				return err
+			}
+			if has {
+				t.Fatalf("block of failed transaction was committed")
+			}
+
+			// writes are not allowed in a read-only transaction
+			if err := txn.AddBlock(blocks[0]); err == nil {
+				t.Fatalf("expected an error when writing in a read-only transaction")
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+}
@@ -0,0 +1,85 @@
+package store
+
+import (
+	"bytes"
+	"encoding/binary"
+	"time"
+
+	"github.com/alexbakker/gonano/nano/block"
+)
+
+const (
+	UncheckedKeySize = block.HashSize * 2
+)
+
+// UncheckedBlock represents a block that could not be added to the ledger yet,
+// because one of the blocks it depends on is missing.
+type UncheckedBlock struct {
+	Block     block.Block
+	Timestamp time.Time
+}
+
+// MarshalBinary implements the encoding.BinaryMarshaler interface.
+func (u *UncheckedBlock) MarshalBinary() ([]byte, error) {
+	buf := new(bytes.Buffer)
+
+	var err error
+	if err = buf.WriteByte(u.Block.ID()); err != nil {
+		return nil, err
+	}
+
+	if err = binary.Write(buf, binary.LittleEndian, u.Timestamp.UnixNano()); err != nil {
+		return nil, err
+	}
+
+	blockBytes, err := u.Block.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	if _, err = buf.Write(blockBytes); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary implements the encoding.BinaryUnmarshaler interface.
+func (u *UncheckedBlock) UnmarshalBinary(data []byte) error {
+	reader := bytes.NewReader(data)
+
+	blockType, err := reader.ReadByte()
+	if err != nil {
+		return err
+	}
+
+	var timestamp int64
+	if err = binary.Read(reader, binary.LittleEndian, &timestamp); err != nil {
+		return err
+	}
+	u.Timestamp = time.Unix(0, timestamp)
+
+	blk, err := block.New(blockType)
+	if err != nil {
+		return err
+	}
+
+	blockBytes := make([]byte, reader.Len())
+	if _, err = reader.Read(blockBytes); err != nil {
+		return err
+	}
+	if err = blk.UnmarshalBinary(blockBytes); err != nil {
+		return err
+	}
+
+	u.Block = blk
+	return nil
+}
+
+// uncheckedTimestamp encodes the given timestamp of an unchecked block for use
+// in the keys of the expiry index. It's encoded in big endian, so that the
+// index is sorted from the oldest to the newest block.
+func uncheckedTimestamp(timestamp time.Time) []byte {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], uint64(timestamp.UnixNano()))
+	return buf[:]
+}
@@ -1,6 +1,8 @@
 package wallet
 
 import (
+	"sync"
+
 	"github.com/alexbakker/gonano/nano/crypto/ed25519"
 )
 
@@ -8,6 +10,15 @@ import (
 type Account struct {
 	pubKey  ed25519.PublicKey
 	privKey ed25519.PrivateKey
+
+	mutex sync.Mutex
+	work  *cachedWork
+}
+
+// cachedWork is precomputed proof of work for the head of an account.
+type cachedWork struct {
+	root [32]byte
+	work uint64
 }
 
 // NewAccount creates a new account with the given private key.
@@ -22,3 +33,47 @@ func NewAccount(key ed25519.PrivateKey) *Account {
 func (a *Account) Address() Address {
 	return Address(a.pubKey)
 }
+
+// Sign signs the given data with the private key of this account.
+func (a *Account) Sign(data []byte) []byte {
+	return ed25519.Sign(a.privKey, data)
+}
+
+// SetWork stores precomputed work for the given root, replacing the work that
+// was stored for the previous head of this account.
+func (a *Account) SetWork(root [32]byte, work uint64) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.work = &cachedWork{root: root, work: work}
+}
+
+// cached returns a copy of the precomputed work of this account, if any.
+func (a *Account) cached() *cachedWork {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if a.work == nil {
+		return nil
+	}
+	work := *a.work
+	return &work
+}
+
+// wipe overwrites the private key of this account with zeroes.
+func (a *Account) wipe() {
+	for i := range a.privKey {
+		a.privKey[i] = 0
+	}
+}
+
+// Work returns the precomputed work for the given root. It reports whether
+// work for that root was stored.
+func (a *Account) Work(root [32]byte) (uint64, bool) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if a.work == nil || a.work.root != root {
+		return 0, false
+	}
+	return a.work.work, true
+}
@@ -12,12 +12,19 @@ import (
 )
 
 const (
-	// AddressLen represents the string length of a Nano address.
-	AddressLen = 64
+	// AddressLen represents the string length of a Nano address with the xrb_
+	// prefix.
+	AddressLen = len(AddressPrefix) + addressEncodedLen
 	// AddressSize represents the binary size of a Nano address (a public key).
 	AddressSize = ed25519.PublicKeySize
-	// AddressPrefix is the prefix of Nano addresses.
+	// AddressPrefix is the original prefix of Nano addresses.
 	AddressPrefix = "xrb_"
+	// AddressPrefixNano is the newer prefix of Nano addresses.
+	AddressPrefixNano = "nano_"
+
+	// addressEncodedLen is the length of the encoded public key and checksum
+	// that follow the prefix.
+	addressEncodedLen = 60
 
 	// AddressEncodingAlphabet is Nano's custom alphabet for base32 encoding
 	AddressEncodingAlphabet = "13456789abcdefghijkmnopqrstuwxyz"
@@ -28,6 +35,8 @@ var (
 	// alphabet.
 	AddressEncoding = base32.NewEncoding(AddressEncodingAlphabet)
 
+	addressPrefixes = []string{AddressPrefix, AddressPrefixNano}
+
 	ErrAddressLen      = errors.New("bad address length")
 	ErrAddressPrefix   = errors.New("bad address prefix")
 	ErrAddressEncoding = errors.New("bad address encoding")
@@ -37,22 +46,30 @@ var (
 // Address represents a Nano address.
 type Address ed25519.PublicKey
 
-// ParseAddress parses the given Nano address string to a public key.
+// ParseAddress parses the given Nano address string to a public key. Both the
+// xrb_ and the nano_ prefix are accepted.
 func ParseAddress(s string) (Address, error) {
-	if len(s) != AddressLen {
-		return nil, ErrAddressLen
+	var encoded string
+	for _, prefix := range addressPrefixes {
+		if strings.HasPrefix(s, prefix) {
+			encoded = s[len(prefix):]
+			break
+		}
 	}
-
-	if !strings.HasPrefix(s, AddressPrefix) {
+	if encoded == "" {
 		return nil, ErrAddressPrefix
 	}
 
-	key, err := AddressEncoding.DecodeString("1111" + s[4:56])
+	if len(encoded) != addressEncodedLen {
+		return nil, ErrAddressLen
+	}
+
+	key, err := AddressEncoding.DecodeString("1111" + encoded[:52])
 	if err != nil {
 		return nil, ErrAddressEncoding
 	}
 
-	checksum, err := AddressEncoding.DecodeString(s[56:])
+	checksum, err := AddressEncoding.DecodeString(encoded[52:])
 	if err != nil {
 		return nil, ErrAddressEncoding
 	}
@@ -76,14 +93,21 @@ func (a Address) Checksum() []byte {
 	return util.ReverseBytes(hash.Sum(nil))
 }
 
-// String implements the fmt.Stringer interface.
+// String implements the fmt.Stringer interface. It always uses AddressPrefix as
+// the prefix of the address, use StringWithPrefix for another prefix.
 func (a Address) String() string {
+	return a.StringWithPrefix(AddressPrefix)
+}
+
+// StringWithPrefix returns the string representation of this address with the
+// given prefix.
+func (a Address) StringWithPrefix(prefix string) string {
 	key := append([]byte{0, 0, 0}, a...)
 	encodedKey := AddressEncoding.EncodeToString(key)[4:]
 	encodedChecksum := AddressEncoding.EncodeToString(a.Checksum())
 
 	var buf bytes.Buffer
-	buf.WriteString(AddressPrefix)
+	buf.WriteString(prefix)
 	buf.WriteString(encodedKey)
 	buf.WriteString(encodedChecksum)
 	return buf.String()
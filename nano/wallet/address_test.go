@@ -30,3 +30,40 @@ func TestWalletAddress(t *testing.T) {
 		t.Fatalf("address is not zero")
 	}
 }
+
+func TestWalletAddressPrefix(t *testing.T) {
+	s1 := "xrb_3t6k35gi95xu6tergt6p69ck76ogmitsa8mnijtpxm9fkcm736xtoncuohr3"
+	s2 := "nano_3t6k35gi95xu6tergt6p69ck76ogmitsa8mnijtpxm9fkcm736xtoncuohr3"
+
+	a1, err := ParseAddress(s1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a2, err := ParseAddress(s2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(a1, a2) {
+		t.Fatalf("addresses are not equal")
+	}
+	if a2.StringWithPrefix(AddressPrefixNano) != s2 {
+		t.Fatalf("unexpected address: %s", a2.StringWithPrefix(AddressPrefixNano))
+	}
+
+	// the default prefix doesn't depend on the prefix an address was parsed with
+	text, err := a2.MarshalText()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(text) != s1 || a2.String() != s1 {
+		t.Fatalf("unexpected address: %s", text)
+	}
+
+	for _, s := range []string{"nan_" + s2[5:], "xrb_" + s2[6:], "nano_" + s2[5:] + "1"} {
+		if _, err := ParseAddress(s); err == nil {
+			t.Fatalf("bad address parsed: %s", s)
+		}
+	}
+}
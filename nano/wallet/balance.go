@@ -37,13 +37,21 @@ var (
 
 	ZeroBalance = Balance(uint128.FromInts(0, 0))
 
-	ErrBadBalanceSize = errors.New("balances should be 16 bytes in size")
+	ErrBadBalanceSize      = errors.New("balances should be 16 bytes in size")
+	ErrBadBalanceUnit      = errors.New("unknown balance unit")
+	ErrBadBalancePrecision = errors.New("balances can't be smaller than 1 raw")
+	ErrNegativeBalance     = errors.New("balances can't be negative")
 )
 
 type Balance uint128.Uint128
 
-// ParseBalance parses the given balance string.
+// ParseBalance parses the given balance string in the given unit.
 func ParseBalance(s string, unit string) (Balance, error) {
+	multiplier, ok := units[unit]
+	if !ok {
+		return ZeroBalance, ErrBadBalanceUnit
+	}
+
 	d, err := decimal.NewFromString(s)
 	if err != nil {
 		return ZeroBalance, err
@@ -53,14 +61,30 @@ func ParseBalance(s string, unit string) (Balance, error) {
 	if d.Equals(decimal.Zero) {
 		return ZeroBalance, nil
 	}
+	if d.Sign() < 0 {
+		return ZeroBalance, ErrNegativeBalance
+	}
+
+	d = d.Mul(multiplier)
+	if !d.Equals(d.Truncate(0)) {
+		return ZeroBalance, ErrBadBalancePrecision
+	}
+	d = d.Truncate(0)
 
-	d = d.Mul(units[unit])
 	c := d.Coefficient()
 	f := bigPow(10, int64(d.Exponent()))
 	i := c.Mul(c, f)
 
+	// pad the big-endian representation to the size of a balance
+	intBytes := i.Bytes()
+	if len(intBytes) > BalanceSize {
+		return ZeroBalance, ErrBadBalanceSize
+	}
+	balanceBytes := make([]byte, BalanceSize)
+	copy(balanceBytes[BalanceSize-len(intBytes):], intBytes)
+
 	var balance Balance
-	if err := balance.UnmarshalBinary(i.Bytes()); err != nil {
+	if err := balance.UnmarshalBinary(balanceBytes); err != nil {
 		return ZeroBalance, err
 	}
 
@@ -113,9 +137,13 @@ func (b Balance) Compare(n Balance) BalanceComp {
 	}
 }
 
-// MarshalBinary implements the encoding.BinaryMarshaler interface.
+// MarshalBinary implements the encoding.BinaryMarshaler interface. Balances are
+// encoded in big endian, which is what UnmarshalBinary expects. This used to be
+// little endian, so data that was encoded with an older version has to be
+// converted before it can be decoded. The store does so for the representative
+// weights in databases that predate schema version 1.
 func (b Balance) MarshalBinary() ([]byte, error) {
-	return b.Bytes(binary.LittleEndian), nil
+	return b.Bytes(binary.BigEndian), nil
 }
 
 // UnmarshalBinary implements the encoding.BinaryUnmarshaler interface.
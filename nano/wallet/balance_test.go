@@ -76,4 +76,34 @@ func TestWalletBalance(t *testing.T) {
 			t.Errorf("(%s) expected: %s, got: %s\n", unit, s, res)
 		}
 	}
+
+	b, err := ParseBalance("1000", "raw")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !b.Equal(ParseBalanceInts(0, 1000)) {
+		t.Errorf("expected: 1000, got: %s", b.UnitString("raw", BalanceMaxPrecision))
+	}
+
+	bBytes, err := b.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b3 Balance
+	if err = b3.UnmarshalBinary(bBytes); err != nil {
+		t.Fatal(err)
+	}
+	if !b3.Equal(b) {
+		t.Errorf("binary encoding does not round-trip, got: %s", b3.UnitString("raw", BalanceMaxPrecision))
+	}
+
+	if _, err = ParseBalance("1", "nano"); err != ErrBadBalanceUnit {
+		t.Errorf("expected ErrBadBalanceUnit, got: %v", err)
+	}
+	if _, err = ParseBalance("0.5", "raw"); err != ErrBadBalancePrecision {
+		t.Errorf("expected ErrBadBalancePrecision, got: %v", err)
+	}
+	if _, err = ParseBalance("-1", "Mxrb"); err != ErrNegativeBalance {
+		t.Errorf("expected ErrNegativeBalance, got: %v", err)
+	}
 }
@@ -0,0 +1,114 @@
+package wallet
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+
+	"github.com/alexbakker/gonano/nano/crypto/ed25519"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+const (
+	// BIP39SeedSize is the size of the seed that is derived from a BIP39
+	// mnemonic and passphrase.
+	BIP39SeedSize = 64
+
+	bip39Iterations = 2048
+	bip44Purpose    = 44
+	bip44CoinType   = 165
+
+	slip10HardenedOffset = 0x80000000
+)
+
+// Derivation is the method that is used to derive the keys of the accounts of
+// a wallet from its seed.
+type Derivation byte
+
+const (
+	// DerivationLegacy derives the key of an account by hashing the seed
+	// followed by the index of the account with blake2b, like the reference
+	// wallet does.
+	DerivationLegacy Derivation = iota
+
+	// DerivationBIP44 derives the key of an account along the path
+	// m/44'/165'/index' with SLIP-0010, starting from the BIP39 seed of the
+	// mnemonic of the seed and a passphrase. This is what hardware wallets and
+	// most mobile wallets do.
+	DerivationBIP44
+)
+
+var (
+	ErrBadDerivation = errors.New("unknown key derivation")
+
+	derivationNames = map[Derivation]string{
+		DerivationLegacy: "legacy",
+		DerivationBIP44:  "bip44",
+	}
+
+	slip10Curve = []byte("ed25519 seed")
+)
+
+// ParseDerivation parses the given name of a key derivation method.
+func ParseDerivation(s string) (Derivation, error) {
+	for derivation, name := range derivationNames {
+		if name == s {
+			return derivation, nil
+		}
+	}
+
+	return 0, ErrBadDerivation
+}
+
+// String implements the fmt.Stringer interface.
+func (d Derivation) String() string {
+	return derivationNames[d]
+}
+
+// slip10Key is an extended ed25519 private key as defined by SLIP-0010.
+type slip10Key struct {
+	key       []byte
+	chainCode []byte
+}
+
+func newSLIP10Key(key []byte, data []byte) *slip10Key {
+	mac := hmac.New(sha512.New, key)
+	mac.Write(data)
+	sum := mac.Sum(nil)
+
+	return &slip10Key{
+		key:       sum[:32],
+		chainCode: sum[32:],
+	}
+}
+
+// newSLIP10MasterKey derives the master key from the given seed.
+func newSLIP10MasterKey(seed []byte) *slip10Key {
+	return newSLIP10Key(slip10Curve, seed)
+}
+
+// child derives the hardened child key with the given index. Ed25519 only
+// supports hardened derivation, so the index is always hardened.
+func (k *slip10Key) child(index uint32) *slip10Key {
+	data := make([]byte, 1+len(k.key)+4)
+	copy(data[1:], k.key)
+	binary.BigEndian.PutUint32(data[1+len(k.key):], index|slip10HardenedOffset)
+	return newSLIP10Key(k.chainCode, data)
+}
+
+// BIP39Seed derives the BIP39 seed of the mnemonic of this seed and the given
+// passphrase.
+func (s *Seed) BIP39Seed(passphrase string) []byte {
+	salt := []byte("mnemonic" + passphrase)
+	return pbkdf2.Key([]byte(s.Mnemonic()), salt, bip39Iterations, BIP39SeedSize, sha512.New)
+}
+
+// BIP44Key derives the key of the account with the given index from the given
+// BIP39 seed, along the path m/44'/165'/index'.
+func BIP44Key(bip39Seed []byte, index uint32) (ed25519.PrivateKey, error) {
+	key := newSLIP10MasterKey(bip39Seed).child(bip44Purpose).child(bip44CoinType).child(index)
+	_, privKey, err := ed25519.GenerateKey(bytes.NewReader(key.key))
+	return privKey, err
+}
@@ -0,0 +1,69 @@
+package wallet
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/alexbakker/gonano/nano/internal/util"
+)
+
+func TestWalletSLIP10(t *testing.T) {
+	// test vector 1 for ed25519 of the SLIP-0010 specification
+	key := newSLIP10MasterKey(util.MustDecodeHex("000102030405060708090a0b0c0d0e0f"))
+	if !bytes.Equal(key.key, util.MustDecodeHex("2b4be7f19ee27bbf30c667b642d5f4aa69fd169872f8fc3059c08ebae2eb19e7")) {
+		t.Fatalf("unexpected master key: %x", key.key)
+	}
+	if !bytes.Equal(key.chainCode, util.MustDecodeHex("90046a93de5380a72b5e45010748567d5ea02bbf6522f979e05c0d8d8ca9fffb")) {
+		t.Fatalf("unexpected master chain code: %x", key.chainCode)
+	}
+
+	// m/0'/1'/2'/2'/1000000000'
+	for _, index := range []uint32{0, 1, 2, 2, 1000000000} {
+		key = key.child(index)
+	}
+	if !bytes.Equal(key.key, util.MustDecodeHex("8f94d394a8e8fd6b1bc2f3f49f5c47e385281d5c17e65324b0f62483e37e8793")) {
+		t.Fatalf("unexpected key: %x", key.key)
+	}
+	if !bytes.Equal(key.chainCode, util.MustDecodeHex("68789923a0cac2cd5a29172a475fe9e0fb14cd6adb5ad98a3fa70333e7afa230")) {
+		t.Fatalf("unexpected chain code: %x", key.chainCode)
+	}
+}
+
+func TestWalletBIP44(t *testing.T) {
+	// this vector can be found in the documentation of the reference
+	// implementation
+	seed, err := ParseMnemonic("edge defense waste choose enrich upon flee junk siren film clown finish luggage leader kid quick brick print evidence swap drill paddle truly occur")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bip39Seed := seed.BIP39Seed("some password")
+	if !bytes.Equal(bip39Seed, util.MustDecodeHex("0dc285fde768f7ff29b66ce7252d56ed92fe003b605907f7a4f683c3dc8586d34a914d3c71fc099bb38ee4a59e5b081a3497b7a323e90cc68f67b5837690310c")) {
+		t.Fatalf("unexpected bip39 seed: %x", bip39Seed)
+	}
+
+	key, err := BIP44Key(bip39Seed, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(key[:32], util.MustDecodeHex("3be4fc2ef3f3b7374e6fc4fb6e7bb153f8a2998b3b3dab50853eabe128024143")) {
+		t.Fatalf("unexpected key: %x", []byte(key[:32]))
+	}
+
+	w, err := NewWithDerivation(seed, 1, DerivationBIP44, "some password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if address := w.Accounts()[0].Address(); !bytes.Equal(address, util.MustDecodeHex("5b65b0e8173ee0802c2c3e6c9080d1a16b06de1176c938a924f58670904e82c4")) {
+		t.Fatalf("unexpected address: %s", address)
+	}
+
+	// the legacy derivation should result in different accounts
+	legacy, err := New(seed, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(legacy.Accounts()[0].Address(), w.Accounts()[0].Address()) {
+		t.Fatalf("derivation methods resulted in the same account")
+	}
+}
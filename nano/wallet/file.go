@@ -0,0 +1,355 @@
+package wallet
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/alexbakker/gonano/nano/crypto/random"
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	// FileVersion is the version of the wallet file format that is written by
+	// Save.
+	FileVersion = 1
+
+	fileKeySize  = 32
+	fileSaltSize = 32
+
+	// scrypt parameters for deriving the key from the password
+	fileScryptN = 1 << 15
+	fileScryptR = 8
+	fileScryptP = 1
+
+	// the scrypt parameters of a wallet file are not authenticated, so they
+	// are limited to keep a crafted file from using up all memory or cpu time.
+	// scrypt needs 128 * N * r bytes of memory, which is 1 GiB at most.
+	fileScryptMaxN = 1 << 20
+	fileScryptMaxR = 8
+	fileScryptMaxP = 4
+)
+
+var (
+	ErrBadPassword    = errors.New("bad password")
+	ErrBadFileVersion = errors.New("unsupported wallet file version")
+	ErrBadScrypt      = errors.New("bad scrypt parameters in wallet file")
+	ErrLocked         = errors.New("wallet is locked")
+	ErrNoPassword     = errors.New("wallet has no password")
+)
+
+// walletFile is the on-disk format of a wallet. Everything but the version and
+// the key derivation parameters is encrypted with AES-256-GCM, using a key that
+// is derived from the password with scrypt.
+type walletFile struct {
+	Version int          `json:"version"`
+	Scrypt  scryptParams `json:"scrypt"`
+	Nonce   []byte       `json:"nonce"`
+	Data    []byte       `json:"data"`
+}
+
+type scryptParams struct {
+	Salt []byte `json:"salt"`
+	N    int    `json:"n"`
+	R    int    `json:"r"`
+	P    int    `json:"p"`
+}
+
+// walletData is the encrypted part of a wallet file.
+type walletData struct {
+	Seed       string        `json:"seed"`
+	Index      uint32        `json:"index"`
+	Derivation string        `json:"derivation,omitempty"`
+	Passphrase string        `json:"passphrase,omitempty"`
+	Work       []accountWork `json:"work,omitempty"`
+}
+
+// accountWork is the precomputed work of the account with the given index.
+type accountWork struct {
+	Index uint32 `json:"index"`
+	Root  string `json:"root"`
+	Work  string `json:"work"`
+}
+
+func newScryptParams() (*scryptParams, error) {
+	salt := make([]byte, fileSaltSize)
+	if err := random.Bytes(salt); err != nil {
+		return nil, err
+	}
+
+	return &scryptParams{
+		Salt: salt,
+		N:    fileScryptN,
+		R:    fileScryptR,
+		P:    fileScryptP,
+	}, nil
+}
+
+func (p *scryptParams) valid() bool {
+	return p.N > 1 && p.N <= fileScryptMaxN && p.N&(p.N-1) == 0 &&
+		p.R >= 1 && p.R <= fileScryptMaxR &&
+		p.P >= 1 && p.P <= fileScryptMaxP
+}
+
+func (p *scryptParams) key(password string) ([]byte, error) {
+	if !p.valid() {
+		return nil, ErrBadScrypt
+	}
+	return scrypt.Key([]byte(password), p.Salt, p.N, p.R, p.P, fileKeySize)
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// fileAdditionalData returns the data that is authenticated along with the
+// encrypted part of a wallet file of the given version.
+func fileAdditionalData(version int) []byte {
+	var data [8]byte
+	binary.BigEndian.PutUint64(data[:], uint64(version))
+	return data[:]
+}
+
+// seal encrypts the state of the wallet with its key.
+func (w *Wallet) seal() (*walletFile, error) {
+	if w.Locked() {
+		return nil, ErrLocked
+	}
+	if w.key == nil {
+		return nil, ErrNoPassword
+	}
+
+	data := walletData{
+		Seed:       w.seed.String(),
+		Index:      w.index,
+		Derivation: w.derivation.String(),
+		Passphrase: w.passphrase,
+	}
+	for i, account := range w.accounts {
+		if work := account.cached(); work != nil {
+			data.Work = append(data.Work, accountWork{
+				Index: uint32(i),
+				Root:  hex.EncodeToString(work.root[:]),
+				Work:  fmt.Sprintf("%016x", work.work),
+			})
+		}
+	}
+
+	plaintext, err := json.Marshal(&data)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := newAEAD(w.key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if err = random.Bytes(nonce); err != nil {
+		return nil, err
+	}
+
+	return &walletFile{
+		Version: FileVersion,
+		Scrypt:  *w.params,
+		Nonce:   nonce,
+		Data:    aead.Seal(nil, nonce, plaintext, fileAdditionalData(FileVersion)),
+	}, nil
+}
+
+// open decrypts the given wallet file with the given password and restores the
+// state of the wallet from it.
+func (w *Wallet) open(file *walletFile, password string) error {
+	if file.Version != FileVersion {
+		return ErrBadFileVersion
+	}
+
+	key, err := file.Scrypt.key(password)
+	if err != nil {
+		return err
+	}
+
+	aead, err := newAEAD(key)
+	if err != nil {
+		return err
+	}
+	if len(file.Nonce) != aead.NonceSize() {
+		return fmt.Errorf("bad wallet nonce size: %d", len(file.Nonce))
+	}
+
+	plaintext, err := aead.Open(nil, file.Nonce, file.Data, fileAdditionalData(file.Version))
+	if err != nil {
+		return ErrBadPassword
+	}
+
+	var data walletData
+	if err = json.Unmarshal(plaintext, &data); err != nil {
+		return err
+	}
+
+	seed, err := ParseSeed(data.Seed)
+	if err != nil {
+		return err
+	}
+
+	// wallets without a derivation method use the legacy one
+	derivation := DerivationLegacy
+	if data.Derivation != "" {
+		if derivation, err = ParseDerivation(data.Derivation); err != nil {
+			return err
+		}
+	}
+
+	res, err := NewWithDerivation(seed, data.Index, derivation, data.Passphrase)
+	if err != nil {
+		return err
+	}
+
+	for _, work := range data.Work {
+		if work.Index >= uint32(len(res.accounts)) {
+			return fmt.Errorf("bad work account index: %d", work.Index)
+		}
+
+		rootBytes, err := hex.DecodeString(work.Root)
+		if err != nil || len(rootBytes) != 32 {
+			return errors.New("bad work root")
+		}
+		var root [32]byte
+		copy(root[:], rootBytes)
+
+		value, err := hex.DecodeString(work.Work)
+		if err != nil || len(value) != 8 {
+			return errors.New("bad work value")
+		}
+		res.accounts[work.Index].SetWork(root, binary.BigEndian.Uint64(value))
+	}
+
+	w.seed = res.seed
+	w.accounts = res.accounts
+	w.index = res.index
+	w.derivation = res.derivation
+	w.passphrase = res.passphrase
+	w.bip39Seed = res.bip39Seed
+	w.params = &file.Scrypt
+	w.key = key
+	w.sealed = nil
+	return nil
+}
+
+// Load reads the wallet file with the given filename and decrypts it with the
+// given password.
+func Load(filename string, password string) (*Wallet, error) {
+	bytes, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var file walletFile
+	if err = json.Unmarshal(bytes, &file); err != nil {
+		return nil, err
+	}
+
+	w := new(Wallet)
+	if err = w.open(&file, password); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Save encrypts the wallet and writes it to the file with the given filename.
+// The wallet needs to be unlocked and have a password.
+func (w *Wallet) Save(filename string) error {
+	file, err := w.seal()
+	if err != nil {
+		return err
+	}
+
+	bytes, err := json.MarshalIndent(file, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	// write to a temporary file first so that a failed write doesn't leave a
+	// corrupt wallet behind
+	tmp := filename + ".tmp"
+	if err = ioutil.WriteFile(tmp, bytes, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filename)
+}
+
+// ChangePassword changes the password of the wallet. This is also how a
+// password is set for a new wallet. The wallet needs to be unlocked.
+func (w *Wallet) ChangePassword(password string) error {
+	if w.Locked() {
+		return ErrLocked
+	}
+
+	params, err := newScryptParams()
+	if err != nil {
+		return err
+	}
+
+	key, err := params.key(password)
+	if err != nil {
+		return err
+	}
+
+	w.params = params
+	w.key = key
+	return nil
+}
+
+// Lock encrypts the state of the wallet and wipes the seed and private keys
+// from memory. The wallet needs to have a password.
+func (w *Wallet) Lock() error {
+	file, err := w.seal()
+	if err != nil {
+		return err
+	}
+
+	for i := range w.seed {
+		w.seed[i] = 0
+	}
+	for i := range w.key {
+		w.key[i] = 0
+	}
+	for i := range w.bip39Seed {
+		w.bip39Seed[i] = 0
+	}
+	for _, account := range w.accounts {
+		account.wipe()
+	}
+
+	w.seed = nil
+	w.key = nil
+	w.bip39Seed = nil
+	w.passphrase = ""
+	w.accounts = nil
+	w.sealed = file
+	return nil
+}
+
+// Unlock decrypts the state of a locked wallet with the given password.
+func (w *Wallet) Unlock(password string) error {
+	if !w.Locked() {
+		return nil
+	}
+	return w.open(w.sealed, password)
+}
+
+// Locked reports whether the wallet is locked.
+func (w *Wallet) Locked() bool {
+	return w.sealed != nil
+}
@@ -0,0 +1,100 @@
+package wallet
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+const (
+	// MnemonicSize is the amount of words in the mnemonic of a seed.
+	MnemonicSize = 24
+
+	mnemonicWordCount = 2048
+	mnemonicWordBits  = 11
+)
+
+var (
+	ErrBadMnemonicSize     = fmt.Errorf("mnemonics should consist of %d words", MnemonicSize)
+	ErrBadMnemonicChecksum = errors.New("bad mnemonic checksum")
+
+	mnemonicIndices = make(map[string]int, mnemonicWordCount)
+)
+
+// ErrBadMnemonicWord is returned when a mnemonic contains a word that is not in
+// the BIP39 word list.
+type ErrBadMnemonicWord struct {
+	// Position is the position of the word in the mnemonic, starting at 1.
+	Position int
+	Word     string
+}
+
+func init() {
+	for i, word := range mnemonicWords {
+		mnemonicIndices[word] = i
+	}
+}
+
+func (e *ErrBadMnemonicWord) Error() string {
+	return fmt.Sprintf("unknown mnemonic word %q at position %d", e.Word, e.Position)
+}
+
+// mnemonicChecksum returns the checksum of the given seed, which is the first
+// byte of its SHA-256 hash.
+func mnemonicChecksum(seed []byte) byte {
+	hash := sha256.Sum256(seed)
+	return hash[0]
+}
+
+// ParseMnemonic parses the given BIP39 mnemonic of 24 English words into a
+// seed. The words are separated by whitespace and are case-insensitive.
+func ParseMnemonic(s string) (*Seed, error) {
+	words := strings.Fields(strings.ToLower(s))
+	if len(words) != MnemonicSize {
+		return nil, ErrBadMnemonicSize
+	}
+
+	// the words encode the seed followed by a checksum of 8 bits
+	n := new(big.Int)
+	for i, word := range words {
+		index, ok := mnemonicIndices[word]
+		if !ok {
+			return nil, &ErrBadMnemonicWord{Position: i + 1, Word: word}
+		}
+		n.Lsh(n, mnemonicWordBits)
+		n.Or(n, big.NewInt(int64(index)))
+	}
+
+	checksum := byte(n.Uint64())
+	n.Rsh(n, 8)
+
+	seed := new(Seed)
+	bytes := n.Bytes()
+	copy(seed[SeedSize-len(bytes):], bytes)
+
+	if mnemonicChecksum(seed[:]) != checksum {
+		return nil, ErrBadMnemonicChecksum
+	}
+
+	return seed, nil
+}
+
+// Mnemonic returns the BIP39 mnemonic of 24 English words that encodes this
+// seed.
+func (s *Seed) Mnemonic() string {
+	n := new(big.Int).SetBytes(s[:])
+	n.Lsh(n, 8)
+	n.Or(n, big.NewInt(int64(mnemonicChecksum(s[:]))))
+
+	mask := big.NewInt(mnemonicWordCount - 1)
+	words := make([]string, MnemonicSize)
+	for i := MnemonicSize - 1; i >= 0; i-- {
+		index := new(big.Int).And(n, mask)
+		words[i] = mnemonicWords[index.Int64()]
+		n.Rsh(n, mnemonicWordBits)
+	}
+
+	return strings.Join(words, " ")
+}
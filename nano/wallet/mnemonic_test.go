@@ -0,0 +1,96 @@
+package wallet
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/alexbakker/gonano/nano/internal/util"
+)
+
+// These vectors are the 256-bit vectors of the BIP39 specification, without
+// a passphrase.
+var mnemonicVectors = []struct {
+	Seed     string
+	Mnemonic string
+}{
+	{
+		"0000000000000000000000000000000000000000000000000000000000000000",
+		"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon art",
+	},
+	{
+		"7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f",
+		"legal winner thank year wave sausage worth useful legal winner thank year wave sausage worth useful legal winner thank year wave sausage worth title",
+	},
+	{
+		"8080808080808080808080808080808080808080808080808080808080808080",
+		"letter advice cage absurd amount doctor acoustic avoid letter advice cage absurd amount doctor acoustic avoid letter advice cage absurd amount doctor acoustic bless",
+	},
+	{
+		"ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff",
+		"zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo vote",
+	},
+}
+
+func TestWalletMnemonic(t *testing.T) {
+	for _, vector := range mnemonicVectors {
+		var seed Seed
+		copy(seed[:], util.MustDecodeHex(vector.Seed))
+
+		if mnemonic := seed.Mnemonic(); mnemonic != vector.Mnemonic {
+			t.Fatalf("unexpected mnemonic: %s", mnemonic)
+		}
+
+		res, err := ParseMnemonic(vector.Mnemonic)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if *res != seed {
+			t.Fatalf("unexpected seed: %s", res)
+		}
+	}
+}
+
+func TestWalletMnemonicNew(t *testing.T) {
+	mnemonic := "guess kiwi obscure system velvet demand noise hub call open flock sign search cram route assist hunt patient bubble traffic faith mountain loyal common"
+
+	// extra whitespace and capitals should be accepted
+	seed, err := ParseMnemonic("  " + strings.ToUpper(mnemonic) + "\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := New(seed, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := testWallet(t)
+	if !bytes.Equal(w.Accounts()[0].Address(), expected.Accounts()[0].Address()) {
+		t.Fatalf("unexpected address: %s", w.Accounts()[0].Address())
+	}
+	if w.Seed().Mnemonic() != mnemonic {
+		t.Fatalf("unexpected mnemonic: %s", w.Seed().Mnemonic())
+	}
+}
+
+func TestWalletMnemonicErrors(t *testing.T) {
+	words := strings.Fields(mnemonicVectors[1].Mnemonic)
+
+	bad := append([]string{}, words...)
+	bad[4] = "wav"
+	_, err := ParseMnemonic(strings.Join(bad, " "))
+	if wordErr, ok := err.(*ErrBadMnemonicWord); !ok || wordErr.Position != 5 || wordErr.Word != "wav" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	bad = append([]string{}, words...)
+	bad[23] = "zoo"
+	if _, err = ParseMnemonic(strings.Join(bad, " ")); err != ErrBadMnemonicChecksum {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err = ParseMnemonic(strings.Join(words[1:], " ")); err != ErrBadMnemonicSize {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,92 @@
+package wallet
+
+import (
+	"errors"
+	"net/url"
+)
+
+const (
+	// PaymentScheme is the URI scheme of Nano payment requests.
+	PaymentScheme = "nano"
+	// PaymentSchemeLegacy is the URI scheme that was used by older wallets.
+	PaymentSchemeLegacy = "xrb"
+)
+
+var (
+	ErrPaymentScheme = errors.New("bad payment request scheme")
+	ErrPaymentURI    = errors.New("bad payment request uri")
+)
+
+// PaymentRequest represents a request for a payment to an address, encoded as
+// a URI of the form nano:<address>?amount=<raw>&label=<label>&message=<message>.
+type PaymentRequest struct {
+	Address Address
+	// Amount is the requested amount. A zero amount means that the amount is
+	// left to the payer.
+	Amount  Balance
+	Label   string
+	Message string
+}
+
+// ParsePaymentRequest parses the given payment request URI. Both the nano and
+// the xrb scheme are accepted. The amount is expected to be in raw.
+func ParsePaymentRequest(s string) (*PaymentRequest, error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, err
+	}
+
+	if u.Scheme != PaymentScheme && u.Scheme != PaymentSchemeLegacy {
+		return nil, ErrPaymentScheme
+	}
+	if u.Opaque == "" {
+		return nil, ErrPaymentURI
+	}
+
+	address, err := ParseAddress(u.Opaque)
+	if err != nil {
+		return nil, err
+	}
+
+	query, err := url.ParseQuery(u.RawQuery)
+	if err != nil {
+		return nil, err
+	}
+
+	req := PaymentRequest{
+		Address: address,
+		Amount:  ZeroBalance,
+		Label:   query.Get("label"),
+		Message: query.Get("message"),
+	}
+
+	if amount := query.Get("amount"); amount != "" {
+		if req.Amount, err = ParseBalance(amount, "raw"); err != nil {
+			return nil, err
+		}
+	}
+
+	return &req, nil
+}
+
+// String implements the fmt.Stringer interface. It returns the payment request
+// as a URI with the nano scheme and an address with the matching nano_ prefix.
+func (r *PaymentRequest) String() string {
+	query := url.Values{}
+	if !r.Amount.Equal(ZeroBalance) {
+		query.Set("amount", r.Amount.UnitString("raw", 0))
+	}
+	if r.Label != "" {
+		query.Set("label", r.Label)
+	}
+	if r.Message != "" {
+		query.Set("message", r.Message)
+	}
+
+	u := url.URL{
+		Scheme:   PaymentScheme,
+		Opaque:   r.Address.StringWithPrefix(AddressPrefixNano),
+		RawQuery: query.Encode(),
+	}
+	return u.String()
+}
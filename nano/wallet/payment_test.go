@@ -0,0 +1,65 @@
+package wallet
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWalletPaymentRequest(t *testing.T) {
+	uri := "nano:nano_3t6k35gi95xu6tergt6p69ck76ogmitsa8mnijtpxm9fkcm736xtoncuohr3?amount=1000000000000000000000000000000&label=Coffee+shop&message=Order+%2342"
+
+	req, err := ParsePaymentRequest(uri)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	address, err := ParseAddress("xrb_3t6k35gi95xu6tergt6p69ck76ogmitsa8mnijtpxm9fkcm736xtoncuohr3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(req.Address, address) {
+		t.Errorf("unexpected address: %s", req.Address)
+	}
+	if s := req.Amount.UnitString("Mxrb", BalanceMaxPrecision); s != "1" {
+		t.Errorf("expected an amount of 1 Mxrb, got: %s", s)
+	}
+	if req.Label != "Coffee shop" || req.Message != "Order #42" {
+		t.Errorf("unexpected label or message: %q, %q", req.Label, req.Message)
+	}
+
+	if s := req.String(); s != uri {
+		t.Errorf("expected: %s, got: %s", uri, s)
+	}
+
+	req, err = ParsePaymentRequest("xrb:nano_3t6k35gi95xu6tergt6p69ck76ogmitsa8mnijtpxm9fkcm736xtoncuohr3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !req.Amount.Equal(ZeroBalance) {
+		t.Errorf("expected a zero amount")
+	}
+
+	// legacy requests are converted to the nano scheme and prefix
+	req, err = ParsePaymentRequest("xrb:xrb_3t6k35gi95xu6tergt6p69ck76ogmitsa8mnijtpxm9fkcm736xtoncuohr3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := req.String(); s != "nano:nano_3t6k35gi95xu6tergt6p69ck76ogmitsa8mnijtpxm9fkcm736xtoncuohr3" {
+		t.Errorf("unexpected uri: %s", s)
+	}
+
+	bad := []string{
+		"bitcoin:xrb_3t6k35gi95xu6tergt6p69ck76ogmitsa8mnijtpxm9fkcm736xtoncuohr3",
+		"nano:",
+		"nano:xrb_3t6k35gi95xu6tergt6p69ck76ogmitsa8mnijtpxm9fkcm736xtoncuohr4",
+		"nano:xrb_3t6k35gi95xu6tergt6p69ck76ogmitsa8mnijtpxm9fkcm736xtoncuohr3?amount=1.5",
+		"nano:xrb_3t6k35gi95xu6tergt6p69ck76ogmitsa8mnijtpxm9fkcm736xtoncuohr3?amount=-1",
+		"nano:xrb_3t6k35gi95xu6tergt6p69ck76ogmitsa8mnijtpxm9fkcm736xtoncuohr3?amount=abc",
+		"nano:xrb_3t6k35gi95xu6tergt6p69ck76ogmitsa8mnijtpxm9fkcm736xtoncuohr3?amount=340282366920938463463374607431768211456",
+	}
+	for _, s := range bad {
+		if _, err := ParsePaymentRequest(s); err == nil {
+			t.Errorf("expected an error for: %s", s)
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/binary"
 	"encoding/hex"
+	"errors"
 
 	"github.com/alexbakker/gonano/nano/crypto/ed25519"
 	"github.com/alexbakker/gonano/nano/crypto/random"
@@ -16,6 +17,25 @@ const (
 
 type Seed [SeedSize]byte
 
+var (
+	ErrBadSeedSize = errors.New("seeds should be 32 bytes in size")
+)
+
+// ParseSeed parses the given hex encoded seed.
+func ParseSeed(s string) (*Seed, error) {
+	bytes, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(bytes) != SeedSize {
+		return nil, ErrBadSeedSize
+	}
+
+	seed := new(Seed)
+	copy(seed[:], bytes)
+	return seed, nil
+}
+
 func GenerateSeed() (*Seed, error) {
 	seed := new(Seed)
 	if err := random.Bytes(seed[:]); err != nil {
@@ -1,27 +1,67 @@
 package wallet
 
+import (
+	"bytes"
+
+	"github.com/alexbakker/gonano/nano/crypto/ed25519"
+)
+
 type Wallet struct {
 	seed     *Seed
 	accounts []*Account
 	index    uint32
+
+	// the method that is used to derive the keys of the accounts, the BIP39
+	// passphrase and the BIP39 seed that is derived from it
+	derivation Derivation
+	passphrase string
+	bip39Seed  []byte
+
+	// the key that is derived from the password and the parameters that were
+	// used to derive it
+	params *scryptParams
+	key    []byte
+
+	// the encrypted state of the wallet while it's locked
+	sealed *walletFile
 }
 
+// New creates a wallet with the accounts up to and including the given index,
+// using the legacy key derivation.
 func New(seed *Seed, index uint32) (*Wallet, error) {
-	accounts := []*Account{}
+	return NewWithDerivation(seed, index, DerivationLegacy, "")
+}
+
+// NewWithDerivation creates a wallet with the accounts up to and including the
+// given index, using the given key derivation. The passphrase is only used for
+// BIP44 derivation.
+func NewWithDerivation(seed *Seed, index uint32, derivation Derivation, passphrase string) (*Wallet, error) {
+	w := &Wallet{
+		seed:       seed,
+		accounts:   []*Account{},
+		index:      index,
+		derivation: derivation,
+		passphrase: passphrase,
+	}
+
+	switch derivation {
+	case DerivationLegacy:
+	case DerivationBIP44:
+		w.bip39Seed = seed.BIP39Seed(passphrase)
+	default:
+		return nil, ErrBadDerivation
+	}
+
 	for i := uint32(0); i < index+1; i++ {
-		key, err := seed.Key(i)
+		key, err := w.deriveKey(i)
 		if err != nil {
 			return nil, err
 		}
 
-		accounts = append(accounts, NewAccount(key))
+		w.accounts = append(w.accounts, NewAccount(key))
 	}
 
-	return &Wallet{
-		seed:     seed,
-		accounts: accounts,
-		index:    index,
-	}, nil
+	return w, nil
 }
 
 func Generate() (*Wallet, error) {
@@ -39,3 +79,58 @@ func (w *Wallet) Accounts() []*Account {
 	copy(accounts, w.accounts)
 	return accounts
 }
+
+// NewAccount derives the account with the next index from the seed of the
+// wallet and adds it to the wallet.
+func (w *Wallet) NewAccount() (*Account, error) {
+	if w.Locked() {
+		return nil, ErrLocked
+	}
+
+	key, err := w.deriveKey(w.index + 1)
+	if err != nil {
+		return nil, err
+	}
+
+	account := NewAccount(key)
+	w.accounts = append(w.accounts, account)
+	w.index++
+	return account, nil
+}
+
+// Account returns the account with the given address, or nil if it's not part
+// of the wallet.
+func (w *Wallet) Account(address Address) *Account {
+	for _, account := range w.accounts {
+		if bytes.Equal(account.Address(), address) {
+			return account
+		}
+	}
+
+	return nil
+}
+
+// Seed returns a copy of the seed of the wallet, or nil if the wallet is
+// locked.
+func (w *Wallet) Seed() *Seed {
+	if w.Locked() {
+		return nil
+	}
+
+	seed := *w.seed
+	return &seed
+}
+
+// Derivation returns the method that is used to derive the keys of the accounts
+// of the wallet.
+func (w *Wallet) Derivation() Derivation {
+	return w.derivation
+}
+
+// deriveKey derives the key of the account with the given index.
+func (w *Wallet) deriveKey(index uint32) (ed25519.PrivateKey, error) {
+	if w.derivation == DerivationBIP44 {
+		return BIP44Key(w.bip39Seed, index)
+	}
+	return w.seed.Key(index)
+}
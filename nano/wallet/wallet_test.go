@@ -0,0 +1,239 @@
+package wallet
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func testWallet(t *testing.T) *Wallet {
+	var seed Seed
+	copy(seed[:], "gonano test seed, do not use!!!!")
+
+	w, err := New(&seed, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return w
+}
+
+func testWalletFile(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "wallet")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return filepath.Join(dir, "wallet.json"), func() {
+		os.RemoveAll(dir)
+	}
+}
+
+func equalAccounts(t *testing.T, w1 *Wallet, w2 *Wallet) {
+	a1, a2 := w1.Accounts(), w2.Accounts()
+	if len(a1) != len(a2) {
+		t.Fatalf("account count not equal: %d != %d", len(a1), len(a2))
+	}
+
+	for i := range a1 {
+		if !bytes.Equal(a1[i].Address(), a2[i].Address()) {
+			t.Fatalf("account %d not equal", i)
+		}
+	}
+}
+
+func TestWalletFile(t *testing.T) {
+	filename, cleanup := testWalletFile(t)
+	defer cleanup()
+
+	w := testWallet(t)
+	if err := w.Save(filename); err != ErrNoPassword {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	root := [32]byte{1, 2, 3}
+	w.Accounts()[1].SetWork(root, 0x1337)
+
+	if err := w.ChangePassword("password"); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Save(filename); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Load(filename, "wrong password"); err != ErrBadPassword {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	res, err := Load(filename, "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	equalAccounts(t, w, res)
+
+	if work, ok := res.Accounts()[1].Work(root); !ok || work != 0x1337 {
+		t.Fatalf("cached work not loaded")
+	}
+
+	// change the password and make sure the old one doesn't work anymore
+	if err = res.ChangePassword("new password"); err != nil {
+		t.Fatal(err)
+	}
+	if err = res.Save(filename); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = Load(filename, "password"); err != ErrBadPassword {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err = Load(filename, "new password"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWalletFileVersion(t *testing.T) {
+	filename, cleanup := testWalletFile(t)
+	defer cleanup()
+
+	writeTestWalletFile(t, filename, func(file *walletFile) {
+		file.Version = FileVersion + 1
+	})
+	if _, err := Load(filename, "password"); err != ErrBadFileVersion {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestWalletFileScrypt(t *testing.T) {
+	filename, cleanup := testWalletFile(t)
+	defer cleanup()
+
+	params := []scryptParams{
+		{N: 0, R: fileScryptR, P: fileScryptP},
+		{N: fileScryptN + 1, R: fileScryptR, P: fileScryptP},
+		{N: fileScryptMaxN << 1, R: fileScryptR, P: fileScryptP},
+		{N: fileScryptN, R: 0, P: fileScryptP},
+		{N: fileScryptN, R: fileScryptMaxR + 1, P: fileScryptP},
+		{N: fileScryptN, R: fileScryptR, P: 0},
+		{N: fileScryptN, R: fileScryptR, P: fileScryptMaxP + 1},
+	}
+
+	for _, param := range params {
+		writeTestWalletFile(t, filename, func(file *walletFile) {
+			file.Scrypt.N = param.N
+			file.Scrypt.R = param.R
+			file.Scrypt.P = param.P
+		})
+		if _, err := Load(filename, "password"); err != ErrBadScrypt {
+			t.Fatalf("unexpected error for n=%d, r=%d, p=%d: %v", param.N, param.R, param.P, err)
+		}
+	}
+}
+
+// writeTestWalletFile saves a test wallet with the password "password" to the
+// given file, after modifying its contents with the given function.
+func writeTestWalletFile(t *testing.T, filename string, fn func(file *walletFile)) {
+	w := testWallet(t)
+	if err := w.ChangePassword("password"); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Save(filename); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var file walletFile
+	if err = json.Unmarshal(data, &file); err != nil {
+		t.Fatal(err)
+	}
+	fn(&file)
+
+	if data, err = json.Marshal(&file); err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(filename, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWalletLock(t *testing.T) {
+	w := testWallet(t)
+	if err := w.Lock(); err != ErrNoPassword {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := testWallet(t)
+	if err := w.ChangePassword("password"); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Lock(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !w.Locked() || len(w.Accounts()) != 0 {
+		t.Fatalf("wallet not locked")
+	}
+	if err := w.Save("wallet.json"); err != ErrLocked {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := w.Unlock("wrong password"); err != ErrBadPassword {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := w.Unlock("password"); err != nil {
+		t.Fatal(err)
+	}
+
+	if w.Locked() {
+		t.Fatalf("wallet still locked")
+	}
+	equalAccounts(t, w, expected)
+}
+
+func TestWalletNewAccount(t *testing.T) {
+	w := testWallet(t)
+	account, err := w.NewAccount()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := w.Seed().Key(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(account.Address(), NewAccount(key).Address()) {
+		t.Fatalf("unexpected account address: %s", account.Address())
+	}
+
+	if len(w.Accounts()) != 4 || w.Account(account.Address()) != account {
+		t.Fatalf("account not added to the wallet")
+	}
+}
+
+func TestWalletFileDerivation(t *testing.T) {
+	filename, cleanup := testWalletFile(t)
+	defer cleanup()
+
+	w, err := NewWithDerivation(testWallet(t).Seed(), 1, DerivationBIP44, "passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = w.ChangePassword("password"); err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Save(filename); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := Load(filename, "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Derivation() != DerivationBIP44 {
+		t.Fatalf("unexpected derivation: %s", res.Derivation())
+	}
+	equalAccounts(t, w, res)
+}